@@ -1,26 +1,131 @@
 package timingwheel
 
 import (
-	"container/list"
+	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
 // Timer represents a single event. When the Timer expires, the given
 // task will be executed.
 type Timer struct {
-	expiration int64 // in milliseconds
+	// 64-bit atomic operations require 64-bit alignment, but 32-bit
+	// compilers do not ensure it. So we must keep the 64-bit field
+	// as the first field of the struct.
+	//
+	// NOTE: This field may be updated and read concurrently,
+	// through Timer.Reset() and Bucket.Flush().
+	expiration int64 // in nanoseconds
 	task       func()
 
-	// The bucket that holds the list to which this timer's element belongs.
+	// The metadata of the timer, which never changes after creation.
+	id      uint64
+	name    string
+	payload interface{}
+
+	// C is the channel on which the time is delivered when the timer
+	// expires. It is only set for timers created by NewTimer.
+	C <-chan time.Time
+
+	// Whether the task is cheap and non-blocking, and thus can be executed
+	// in the timing wheel's goroutine directly.
+	inline bool
+
+	// For a recurring timer, reschedule returns the next expiration given
+	// the current one, or false if there are no more executions. It is called
+	// each time the timer expires, before the task is executed.
+	reschedule func(expiration int64) (int64, bool)
+
+	// Whether to stop the timer if its task panics.
+	cancelOnPanic bool
+
+	// The misfire policy of a recurring timer, along with the number of the
+	// executions skipped by the policy since the previous execution.
+	misfire MisfirePolicy
+	missed  int32
+
+	// If not nil, execTask is called instead of task, with the details of
+	// the execution.
+	execTask func(Execution)
+
+	// For a fixed-delay timer, delayed is 1 while the timer is waiting for
+	// its task to return, after which rearm is called to reschedule it. The
+	// timer is fixed-delay only if rearm is set, since fixedDelay is also set
+	// for a one-shot timer created with FixedDelay.
+	fixedDelay bool
+	delayed    int32
+	rearm      func()
+
+	// Whether the fixed-delay timer has been reset while its task is
+	// running, in which case rearm reschedules it at its new expiration.
+	// It is guarded by mu.
+	resetting bool
+
+	// Whether to skip an execution if the previous one is still running,
+	// which is indicated by running.
+	noOverlap bool
+	running   int32
+
+	// If not nil, release is called each time Stop is called, and once the
+	// timer is done, i.e. it has expired (for a recurring timer, its
+	// execution plan has ended).
+	release func()
+
+	// The timing wheel to which this timer belongs.
+	tw *TimingWheel
+
+	// mu serializes Stop and Reset.
+	mu sync.Mutex
+
+	// The bucket that holds the list to which this timer belongs.
 	//
 	// NOTE: This field may be updated and read concurrently,
 	// through Timer.Stop() and Bucket.Flush().
 	b unsafe.Pointer // type: *bucket
 
-	// The timer's element.
-	element *list.Element
+	// The previous and next timers in the list, which are protected
+	// by the mutex of the bucket.
+	prev, next *Timer
+}
+
+// ID returns the ID of the timer, which is unique within its timing wheel.
+func (t *Timer) ID() uint64 {
+	return t.id
+}
+
+// Name returns the name of the timer set by WithName.
+func (t *Timer) Name() string {
+	return t.name
+}
+
+// Payload returns the value attached to the timer by WithPayload.
+func (t *Timer) Payload() interface{} {
+	return t.payload
+}
+
+// String returns a description of the timer for debugging.
+func (t *Timer) String() string {
+	s := "timer#" + strconv.FormatUint(t.id, 10)
+	if t.name != "" {
+		s += "(" + t.name + ")"
+	}
+	return s + " expiring at " + t.Expiration().Format(time.RFC3339Nano)
+}
+
+// Expiration returns the time at which the timer expires (or expired).
+// For a recurring timer, it is the next execution time.
+func (t *Timer) Expiration() time.Time {
+	return nsToTime(t.getExpiration())
+}
+
+func (t *Timer) getExpiration() int64 {
+	return atomic.LoadInt64(&t.expiration)
+}
+
+func (t *Timer) setExpiration(expiration int64) {
+	atomic.StoreInt64(&t.expiration, expiration)
 }
 
 func (t *Timer) getBucket() *bucket {
@@ -38,18 +143,126 @@ func (t *Timer) setBucket(b *bucket) {
 // goroutine; Stop does not wait for t.task to complete before returning. If the caller
 // needs to know whether t.task is completed, it must coordinate with t.task explicitly.
 func (t *Timer) Stop() bool {
-	stopped := false
+	t.mu.Lock()
+	stopped := t.stop()
+	t.mu.Unlock()
+
+	if stopped {
+		t.tw.onStop(t)
+	}
+
+	if t.release != nil {
+		t.release()
+	}
+	return stopped
+}
+
+func (t *Timer) stop() (stopped bool) {
+	stopped = t.remove()
+
+	// A fixed-delay timer, whose task is running, is stopped from being
+	// rearmed. This is checked after t has been removed, since t may have
+	// just expired while being flushed.
+	if atomic.CompareAndSwapInt32(&t.delayed, 1, 0) {
+		stopped = true
+	}
+	return stopped
+}
+
+// remove removes t from its bucket, if any. It returns true if t has been
+// removed.
+func (t *Timer) remove() (removed bool) {
 	for b := t.getBucket(); b != nil; b = t.getBucket() {
 		// If b.Remove is called just after the timing wheel's goroutine has:
-		//     1. removed t from b (through b.Flush -> b.remove)
-		//     2. moved t from b to another bucket ab (through b.Flush -> b.remove and ab.Add)
+		//     1. removed t from b and run t.task (through b.Flush -> reinsert)
+		//     2. moved t from b to another bucket ab (through b.Flush -> reinsert -> ab.Add)
 		// this may fail to remove t due to the change of t's bucket.
-		stopped = b.Remove(t)
+		removed = b.Remove(t)
 
 		// Thus, here we re-get t's possibly new bucket (nil for case 1, or ab (non-nil) for case 2),
 		// and retry until the bucket becomes nil, which indicates that t has finally been removed.
 	}
-	return stopped
+	return removed
+}
+
+// Reset changes the timer to expire after duration d. It returns true if
+// the timer had been active, false if the timer had expired or been stopped.
+//
+// Reset moves the timer to its new bucket in place, and a concurrent flushing
+// of the timer's old bucket will never cause the timer to fire with its
+// old expiration after Reset returns. As with Stop, if the timer t has already
+// expired and the t.task has been started in its own goroutine, Reset does
+// not wait for t.task to complete before returning.
+//
+// If t is a fixed-delay timer whose task is running, t is rescheduled at its
+// new expiration only after the task returns, see FixedDelay.
+func (t *Timer) Reset(d time.Duration) bool {
+	var buf [2]timerEvent
+
+	t.mu.Lock()
+	active := t.remove()
+	t.setExpiration(timeToNs(t.tw.clock.Now().UTC().Add(d)))
+	if atomic.LoadInt32(&t.delayed) == 1 {
+		// Leave the rescheduling to rearm, thus the next execution will not
+		// overlap the running one.
+		t.resetting = true
+		t.mu.Unlock()
+		return true
+	}
+	events := t.tw.schedule(t, buf[:0])
+	t.mu.Unlock()
+
+	// Handle the events of t after t.mu is released, thus the observer, and
+	// the task (e.g. an inline one) if t has already expired, are free to
+	// stop or reset t.
+	t.tw.runEvents(events)
+	return active
+}
+
+// timerList is an intrusive doubly linked list of timers. Unlike container/list,
+// it allows a timer to be moved from one list to another without allocating
+// a new element.
+type timerList struct {
+	head, tail *Timer
+	len        int
+}
+
+// Len returns the number of timers in l.
+func (l *timerList) Len() int {
+	return l.len
+}
+
+// Front returns the first timer in l, or nil if l is empty.
+func (l *timerList) Front() *Timer {
+	return l.head
+}
+
+// PushBack inserts the timer t at the back of l.
+func (l *timerList) PushBack(t *Timer) {
+	t.prev, t.next = l.tail, nil
+	if l.tail != nil {
+		l.tail.next = t
+	} else {
+		l.head = t
+	}
+	l.tail = t
+	l.len++
+}
+
+// Remove removes the timer t, which must be in l, from l.
+func (l *timerList) Remove(t *Timer) {
+	if t.prev != nil {
+		t.prev.next = t.next
+	} else {
+		l.head = t.next
+	}
+	if t.next != nil {
+		t.next.prev = t.prev
+	} else {
+		l.tail = t.prev
+	}
+	t.prev, t.next = nil, nil
+	l.len--
 }
 
 type bucket struct {
@@ -62,13 +275,18 @@ type bucket struct {
 	expiration int64
 
 	mu     sync.Mutex
-	timers *list.List
+	timers timerList
+
+	// The number of the pending timers in the wheel to which this bucket
+	// belongs, which is shared by all buckets of the wheel.
+	pending *int64
 }
 
-func newBucket() *bucket {
+// newBucket creates a bucket, which counts its timers in pending.
+func newBucket(pending *int64) *bucket {
 	return &bucket{
-		timers:     list.New(),
 		expiration: -1,
+		pending:    pending,
 	}
 }
 
@@ -83,9 +301,9 @@ func (b *bucket) SetExpiration(expiration int64) bool {
 func (b *bucket) Add(t *Timer) {
 	b.mu.Lock()
 
-	e := b.timers.PushBack(t)
+	b.timers.PushBack(t)
 	t.setBucket(b)
-	t.element = e
+	atomic.AddInt64(b.pending, 1)
 
 	b.mu.Unlock()
 }
@@ -93,15 +311,15 @@ func (b *bucket) Add(t *Timer) {
 func (b *bucket) remove(t *Timer) bool {
 	if t.getBucket() != b {
 		// If remove is called from within t.Stop, and this happens just after the timing wheel's goroutine has:
-		//     1. removed t from b (through b.Flush -> b.remove)
-		//     2. moved t from b to another bucket ab (through b.Flush -> b.remove and ab.Add)
+		//     1. removed t from b and run t.task (through b.Flush -> reinsert)
+		//     2. moved t from b to another bucket ab (through b.Flush -> reinsert -> ab.Add)
 		// then t.getBucket will return nil for case 1, or ab (non-nil) for case 2.
 		// In either case, the returned value does not equal to b.
 		return false
 	}
-	b.timers.Remove(t.element)
+	b.timers.Remove(t)
 	t.setBucket(nil)
-	t.element = nil
+	atomic.AddInt64(b.pending, -1)
 	return true
 }
 
@@ -115,19 +333,36 @@ func (b *bucket) Flush(reinsert func(*Timer)) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	for e := b.timers.Front(); e != nil; {
-		next := e.Next()
+	for t := b.timers.Front(); t != nil; {
+		next := t.next
 
-		t := e.Value.(*Timer)
-		b.remove(t)
+		// Unlink t from the list, but leave t's bucket unchanged until t is
+		// reinserted, so that a concurrent t.Stop will wait for the reinsertion
+		// (by locking b.mu) instead of observing a nil bucket too early.
+		b.timers.Remove(t)
+		atomic.AddInt64(b.pending, -1)
 		// Note that this operation will either execute the timer's task, or
 		// insert the timer into another bucket belonging to a lower-level wheel.
 		//
 		// In either case, no further lock operation will happen to b.mu.
 		reinsert(t)
 
-		e = next
+		t = next
 	}
 
 	b.SetExpiration(-1)
 }
+
+// Drain removes all the timers from b, and appends them to timers.
+func (b *bucket) Drain(timers []*Timer) []*Timer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for t := b.timers.Front(); t != nil; t = b.timers.Front() {
+		b.remove(t)
+		timers = append(timers, t)
+	}
+	b.SetExpiration(-1)
+
+	return timers
+}
@@ -3,7 +3,7 @@ package timingwheel
 import "testing"
 
 func TestBucket_Flush(t *testing.T) {
-	b := newBucket()
+	b := newBucket(new(int64))
 
 	b.Add(&Timer{})
 	b.Add(&Timer{})
@@ -0,0 +1,210 @@
+package timingwheel
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of a TimingWheel.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTimer creates a new ClockTimer that will send the current time
+	// on its channel after at least duration d.
+	NewTimer(d time.Duration) ClockTimer
+}
+
+// ClockTimer is a single-use timer created by Clock.NewTimer.
+type ClockTimer interface {
+	// C returns the channel on which the current time is delivered
+	// when the timer expires.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It returns true if the call
+	// stops the timer, false if the timer has already expired or been stopped.
+	Stop() bool
+}
+
+// realClock is a Clock backed by the standard time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) ClockTimer { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+func (t realTimer) Stop() bool          { return t.t.Stop() }
+
+// wheelDriver is implemented by clocks, such as FakeClock, which drive
+// the timing wheels by themselves instead of letting the wheels wait for
+// their buckets to expire in the background.
+type wheelDriver interface {
+	addWheel(tw *TimingWheel)
+	removeWheel(tw *TimingWheel)
+}
+
+// FakeClock is a Clock whose time only changes when it is advanced
+// explicitly, which makes it possible to test the code built on TimingWheel
+// deterministically.
+//
+// A timing wheel using a FakeClock does not run in the background. Instead,
+// the expired buckets of the wheel are flushed by Advance.
+type FakeClock struct {
+	advanceMu sync.Mutex // serializes calls to Advance
+
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	wheels []*TimingWheel
+}
+
+// NewFakeClock creates an instance of FakeClock whose current time is now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of c.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer creates a new ClockTimer that will fire once c has been
+// advanced by at least duration d.
+func (c *FakeClock) NewTimer(d time.Duration) ClockTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{
+		clock:    c,
+		deadline: c.now.Add(d),
+		c:        make(chan time.Time, 1),
+	}
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the current time of c forward by duration d.
+//
+// Time is moved forward step by step, from one expiration to the next,
+// and at each step, the expired timers are fired and the expired buckets of
+// the timing wheels driven by c are flushed. Thus, when Advance returns, every
+// Timer that is due at the new current time has expired, and its task has
+// been started.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.advanceMu.Lock()
+	defer c.advanceMu.Unlock()
+
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		next, ok := c.next()
+		if !ok || next.After(end) {
+			break
+		}
+		if next.After(c.now) {
+			c.now = next
+		}
+		c.fireTimers()
+		wheels := append([]*TimingWheel(nil), c.wheels...)
+		c.mu.Unlock()
+
+		// Flush the wheels without holding the lock, since the tasks
+		// may need to get the current time.
+		for _, tw := range wheels {
+			tw.flushExpired()
+		}
+	}
+
+	if end.After(c.now) {
+		c.now = end
+	}
+	c.mu.Unlock()
+}
+
+// next returns the earliest expiration of all the timers and wheels.
+func (c *FakeClock) next() (next time.Time, ok bool) {
+	for _, t := range c.timers {
+		if !ok || t.deadline.Before(next) {
+			next, ok = t.deadline, true
+		}
+	}
+	for _, tw := range c.wheels {
+		if expiration, found := tw.queue.Peek(); found {
+			deadline := nsToTime(expiration)
+			if !ok || deadline.Before(next) {
+				next, ok = deadline, true
+			}
+		}
+	}
+	return
+}
+
+// fireTimers fires all the timers whose deadlines are not after the current time.
+func (c *FakeClock) fireTimers() {
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			timers = append(timers, t)
+		} else {
+			t.c <- c.now
+		}
+	}
+	for i := len(timers); i < len(c.timers); i++ {
+		c.timers[i] = nil
+	}
+	c.timers = timers
+}
+
+func (c *FakeClock) addWheel(tw *TimingWheel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.wheels = append(c.wheels, tw)
+}
+
+func (c *FakeClock) removeWheel(tw *TimingWheel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, w := range c.wheels {
+		if w == tw {
+			c.wheels = append(c.wheels[:i], c.wheels[i+1:]...)
+			return
+		}
+	}
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	c        chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, ct := range c.timers {
+		if ct == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,280 @@
+package timingwheel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AfterFuncContext is like AfterFunc, except that the timer will be stopped
+// automatically once ctx is done, and that f is called with a context derived
+// from ctx, which is canceled when f returns.
+//
+// The details of the execution can be retrieved from the context passed to
+// f by ExecutionFromContext.
+//
+// No goroutine is started for watching ctx. Once the timer expires or is
+// stopped, it is no longer associated with ctx.
+func (tw *TimingWheel) AfterFuncContext(ctx context.Context, d time.Duration, f func(context.Context), opts ...TimerOption) *Timer {
+	t := &Timer{
+		id:         tw.nextTimerID(),
+		expiration: timeToNs(tw.clock.Now().UTC().Add(d)),
+		tw:         tw,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.execTask = func(e Execution) {
+		runWithContext(withExecution(ctx, e), f)
+	}
+	t.release = bindContext(ctx, t)
+
+	tw.submit(t)
+	stopIfDone(ctx, t)
+
+	return t
+}
+
+// ScheduleFuncContext is like ScheduleFunc, except that the whole execution
+// plan will be terminated automatically once ctx is done, and that f is called
+// with a context derived from ctx, which is canceled when f returns. The number
+// of the executions skipped before each call, due to the misfire policy set by
+// WithMisfirePolicy, can be retrieved from the context by MissedRuns, and the
+// other details of each execution by ExecutionFromContext.
+//
+// No goroutine is started for watching ctx. Once the execution plan ends or
+// is terminated, the timer is no longer associated with ctx.
+func (tw *TimingWheel) ScheduleFuncContext(ctx context.Context, s Scheduler, f func(context.Context), opts ...TimerOption) (t *Timer) {
+	expiration := s.Next(schedulerTime(s, tw.clock.Now()))
+	if expiration.IsZero() {
+		// No time is scheduled, return nil.
+		return
+	}
+
+	t = &Timer{
+		id:         tw.nextTimerID(),
+		expiration: timeToNs(expiration),
+		tw:         tw,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.execTask = func(e Execution) {
+		runWithContext(withExecution(ctx, e), f)
+	}
+	t.reschedule = func(expiration int64) (int64, bool) {
+		next := s.Next(schedulerTime(s, nsToTime(expiration)))
+		if next.IsZero() {
+			// The execution plan ends.
+			return 0, false
+		}
+		return timeToNs(next), true
+	}
+	t.release = bindContext(ctx, t)
+	if t.fixedDelay {
+		tw.delayReschedule(t, s)
+	}
+
+	tw.submit(t)
+	stopIfDone(ctx, t)
+
+	return
+}
+
+// bindContext arranges for the timer t to be stopped once ctx is done.
+// It returns a function that releases the association between t and ctx.
+func bindContext(ctx context.Context, t *Timer) (release func()) {
+	stop := context.AfterFunc(ctx, func() {
+		t.mu.Lock()
+		stopped := t.stop()
+		t.mu.Unlock()
+
+		if stopped {
+			t.tw.onStop(t)
+		}
+	})
+	return func() { stop() }
+}
+
+// stopIfDone stops the timer t if ctx is already done, which covers the
+// case where ctx is done before t is added into the timing wheel.
+func stopIfDone(ctx context.Context, t *Timer) {
+	if ctx.Err() != nil {
+		t.Stop()
+	}
+}
+
+// runWithContext calls f with a context derived from ctx, and cancels the
+// context once f returns.
+func runWithContext(ctx context.Context, f func(context.Context)) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	f(ctx)
+}
+
+// WithDeadline returns a copy of the parent context with the deadline
+// adjusted to be no later than d. It is like the standard context.WithDeadline
+// (https://golang.org/pkg/context/#WithDeadline), except that the deadline
+// is enforced by a timer of the timing wheel instead of a runtime timer,
+// which is much cheaper when there are a large number of contexts.
+//
+// The returned context's Done channel is closed when the deadline expires
+// (with Err returning context.DeadlineExceeded), when the returned cancel
+// function is called, or when the parent context's Done channel is closed,
+// whichever happens first. Calling cancel releases the resources associated
+// with the context, so code should call cancel as soon as the operations
+// running in this context complete.
+func (tw *TimingWheel) WithDeadline(parent context.Context, d time.Time) (context.Context, context.CancelFunc) {
+	if parent == nil {
+		panic("cannot create context from nil parent")
+	}
+	if cur, ok := parent.Deadline(); ok && cur.Before(d) {
+		// The current deadline is already sooner than the new one.
+		return context.WithCancel(parent)
+	}
+
+	c := &deadlineCtx{
+		parent:   parent,
+		deadline: d,
+		done:     make(chan struct{}),
+	}
+
+	if !d.After(tw.clock.Now()) {
+		// The deadline has already passed.
+		c.cancel(context.DeadlineExceeded)
+		return c, func() { c.cancel(context.Canceled) }
+	}
+
+	t := &Timer{
+		id:         tw.nextTimerID(),
+		expiration: timeToNs(d),
+		task: func() {
+			c.cancel(context.DeadlineExceeded)
+		},
+		// Canceling the context is cheap and non-blocking.
+		inline: true,
+		tw:     tw,
+	}
+	stopParent := context.AfterFunc(parent, func() {
+		c.cancel(parent.Err())
+	})
+
+	c.mu.Lock()
+	c.timer, c.stopParent = t, stopParent
+	c.mu.Unlock()
+
+	tw.submit(t)
+	if err := parent.Err(); err != nil {
+		// The parent is already canceled.
+		c.cancel(err)
+	}
+
+	return c, func() { c.cancel(context.Canceled) }
+}
+
+// WithTimeout returns WithDeadline(parent, now.Add(timeout)), where now is
+// the current time of the timing wheel's clock.
+func (tw *TimingWheel) WithTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return tw.WithDeadline(parent, tw.clock.Now().Add(timeout))
+}
+
+// deadlineCtx is a context with a deadline, which is enforced by a timer
+// of the timing wheel.
+//
+// deadlineCtx implements the AfterFunc method, which is recognized by the
+// standard context package, thus propagating the cancellation to the
+// contexts derived from a deadlineCtx requires no extra goroutines.
+type deadlineCtx struct {
+	parent   context.Context
+	deadline time.Time
+	done     chan struct{}
+
+	mu         sync.Mutex
+	err        error
+	funcs      map[*afterFunc]struct{}
+	timer      *Timer
+	stopParent func() bool
+}
+
+type afterFunc struct {
+	f func()
+}
+
+func (c *deadlineCtx) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *deadlineCtx) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *deadlineCtx) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *deadlineCtx) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// AfterFunc arranges to call f in its own goroutine after c is canceled.
+// It returns a function that stops the association of f with c, which
+// returns true if the call stops f from being run.
+func (c *deadlineCtx) AfterFunc(f func()) func() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		go f()
+		return func() bool { return false }
+	}
+
+	af := &afterFunc{f: f}
+	if c.funcs == nil {
+		c.funcs = make(map[*afterFunc]struct{})
+	}
+	c.funcs[af] = struct{}{}
+
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if _, ok := c.funcs[af]; !ok {
+			return false
+		}
+		delete(c.funcs, af)
+		return true
+	}
+}
+
+func (c *deadlineCtx) String() string {
+	return fmt.Sprintf("%v.WithDeadline(%s)", c.parent, c.deadline)
+}
+
+// cancel closes c.done, calls the functions registered by AfterFunc, and
+// releases the timer and the association with the parent.
+func (c *deadlineCtx) cancel(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		// Already canceled.
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	close(c.done)
+	funcs, t, stopParent := c.funcs, c.timer, c.stopParent
+	c.funcs = nil
+	c.mu.Unlock()
+
+	for af := range funcs {
+		go af.f()
+	}
+	if t != nil {
+		t.Stop()
+	}
+	if stopParent != nil {
+		stopParent()
+	}
+}
@@ -0,0 +1,231 @@
+package timingwheel_test
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+)
+
+func TestTimingWheel_AfterFuncContext(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	t.Run("fire", func(t *testing.T) {
+		ctxC := make(chan context.Context, 1)
+		tw.AfterFuncContext(context.Background(), 10*time.Millisecond, func(ctx context.Context) {
+			if err := ctx.Err(); err != nil {
+				t.Errorf("ctx.Err() = %v during f", err)
+			}
+			ctxC <- ctx
+		})
+
+		clock.Advance(10 * time.Millisecond)
+		ctx := <-ctxC
+		<-ctx.Done() // The derived context is canceled once f returns.
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		timer := tw.AfterFuncContext(ctx, 10*time.Millisecond, func(context.Context) {
+			t.Error("Timer fired after ctx is canceled")
+		})
+
+		cancel()
+		// The timer is stopped asynchronously, stop it again to make sure
+		// the stopping has finished before advancing the clock.
+		timer.Stop()
+
+		clock.Advance(10 * time.Millisecond)
+	})
+
+	t.Run("already canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		timer := tw.AfterFuncContext(ctx, 10*time.Millisecond, func(context.Context) {
+			t.Error("Timer fired after ctx is canceled")
+		})
+		if timer.Stop() {
+			t.Fatal("Timer was active after ctx is canceled")
+		}
+
+		clock.Advance(10 * time.Millisecond)
+	})
+}
+
+func TestTimingWheel_ScheduleFuncContext(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &scheduler{intervals: []time.Duration{
+		10 * time.Millisecond,
+		10 * time.Millisecond,
+		10 * time.Millisecond,
+		10 * time.Millisecond,
+	}}
+	ctxC := make(chan context.Context, len(s.intervals))
+	timer := tw.ScheduleFuncContext(ctx, s, func(ctx context.Context) {
+		ctxC <- ctx
+	})
+
+	clock.Advance(20 * time.Millisecond)
+	<-ctxC
+	<-ctxC
+
+	// Canceling ctx terminates the whole execution plan.
+	cancel()
+	timer.Stop()
+
+	clock.Advance(time.Second)
+	select {
+	case <-ctxC:
+		t.Fatal("f was called after ctx is canceled")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestExecutionFromContext(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock, tw := newFakeWheel(t, start, timingwheel.WithInlineTasks())
+
+	var got []timingwheel.Execution
+	record := func(ctx context.Context) {
+		e, ok := timingwheel.ExecutionFromContext(ctx)
+		if !ok {
+			t.Fatal("No execution in the context")
+		}
+		got = append(got, e)
+	}
+	tw.AfterFuncContext(context.Background(), 5*time.Millisecond, record)
+	tw.ScheduleFuncContext(context.Background(), &scheduler{intervals: []time.Duration{
+		10 * time.Millisecond,
+		10 * time.Millisecond,
+	}}, record)
+
+	clock.Advance(20 * time.Millisecond)
+
+	want := []timingwheel.Execution{
+		{Scheduled: start.Add(5 * time.Millisecond), Started: start.Add(5 * time.Millisecond)},
+		{Scheduled: start.Add(10 * time.Millisecond), Started: start.Add(10 * time.Millisecond), Rescheduled: true},
+		{Scheduled: start.Add(20 * time.Millisecond), Started: start.Add(20 * time.Millisecond)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got (%+v) != Want (%+v)", got, want)
+	}
+
+	if _, ok := timingwheel.ExecutionFromContext(context.Background()); ok {
+		t.Fatal("Got an execution from an unrelated context")
+	}
+}
+
+// driftingClock is a fake clock, whose time moves forward by 1µs each time
+// it is read.
+type driftingClock struct {
+	*timingwheel.FakeClock
+	reads int64
+}
+
+func (c *driftingClock) Now() time.Time {
+	n := atomic.AddInt64(&c.reads, 1)
+	return c.FakeClock.Now().Add(time.Duration(n) * time.Microsecond)
+}
+
+func TestTimingWheel_WithDeadline_Expiration(t *testing.T) {
+	o := &recordingObserver{}
+	clock := &driftingClock{FakeClock: timingwheel.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))}
+	tw := timingwheel.NewTimingWheel(time.Millisecond, 20,
+		timingwheel.WithClock(clock),
+		timingwheel.WithObserver(o),
+	)
+	tw.Start()
+	defer tw.Stop()
+
+	// The timer of the context expires exactly at the deadline.
+	deadline := clock.Now().Add(100 * time.Millisecond)
+	_, cancel := tw.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	if len(o.events) != 1 || o.events[0].name != "add" {
+		t.Fatalf("Got (%+v) != Want an add event", o.events)
+	}
+	if got := o.events[0].timer.Expiration(); !got.Equal(deadline) {
+		t.Fatalf("Expiration: Got (%+v) != Want (%+v)", got, deadline)
+	}
+}
+
+func TestTimingWheel_WithTimeout(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		ctx, cancel := tw.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		child, cancelChild := context.WithCancel(ctx)
+		defer cancelChild()
+
+		if deadline, ok := ctx.Deadline(); !ok || !deadline.Equal(clock.Now().Add(100*time.Millisecond)) {
+			t.Fatalf("Unexpected deadline %s", deadline)
+		}
+
+		clock.Advance(99 * time.Millisecond)
+		if err := ctx.Err(); err != nil {
+			t.Fatalf("ctx.Err() = %v before the deadline", err)
+		}
+
+		clock.Advance(time.Millisecond)
+		<-ctx.Done()
+		if err := ctx.Err(); err != context.DeadlineExceeded {
+			t.Fatalf("ctx.Err() = %v, want %v", err, context.DeadlineExceeded)
+		}
+		<-child.Done()
+		if err := child.Err(); err != context.DeadlineExceeded {
+			t.Fatalf("child.Err() = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := tw.WithTimeout(context.Background(), 100*time.Millisecond)
+		cancel()
+		<-ctx.Done()
+		if err := ctx.Err(); err != context.Canceled {
+			t.Fatalf("ctx.Err() = %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("parent canceled", func(t *testing.T) {
+		parent, cancelParent := context.WithCancel(context.Background())
+		ctx, cancel := tw.WithTimeout(parent, 100*time.Millisecond)
+		defer cancel()
+
+		cancelParent()
+		<-ctx.Done()
+		if err := ctx.Err(); err != context.Canceled {
+			t.Fatalf("ctx.Err() = %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("parent deadline is sooner", func(t *testing.T) {
+		parent, cancelParent := tw.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancelParent()
+		ctx, cancel := tw.WithTimeout(parent, 100*time.Millisecond)
+		defer cancel()
+
+		clock.Advance(10 * time.Millisecond)
+		<-ctx.Done()
+		if err := ctx.Err(); err != context.DeadlineExceeded {
+			t.Fatalf("ctx.Err() = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("deadline already passed", func(t *testing.T) {
+		ctx, cancel := tw.WithDeadline(context.Background(), clock.Now().Add(-time.Second))
+		defer cancel()
+		if err := ctx.Err(); err != context.DeadlineExceeded {
+			t.Fatalf("ctx.Err() = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+// Package cron implements timingwheel.Scheduler by cron expressions.
+//
+// For example, to call f at 09:30 on every weekday in Tokyo:
+//
+//	s := cron.MustParse("CRON_TZ=Asia/Tokyo 30 9 * * MON-FRI")
+//	tw.ScheduleFunc(s, f)
+package cron
+
+import (
+	"time"
+)
+
+// searchYears is the number of years to search for the next time, beyond
+// which the schedule is considered to have no next time (e.g. "0 0 30 2 *").
+const searchYears = 5
+
+// Schedule is a schedule parsed from a cron expression, which implements
+// timingwheel.Scheduler.
+type Schedule struct {
+	spec string
+	loc  *time.Location
+
+	second, minute, hour, dom, month, dow uint64
+	// Whether the day-of-month and day-of-week fields start with a star.
+	domStar, dowStar bool
+}
+
+// String returns the cron expression of s.
+func (s *Schedule) String() string {
+	return s.spec
+}
+
+// Location returns the time zone in which s is interpreted.
+func (s *Schedule) Location() *time.Location {
+	return s.loc
+}
+
+// Next returns the earliest time after prev which matches s, in UTC. It
+// returns a zero time if there is no such time within five years.
+//
+// The times are matched against the wall clock of the location of s. When
+// the wall clock jumps due to daylight saving time (DST):
+//
+//   - The matching times skipped by the wall clock (when the clock springs
+//     forward) are executed once, at the moment of the transition. E.g. in
+//     Europe/Berlin, "30 2 * * *" is executed at 03:00 CEST on the day when
+//     the clock springs forward from 02:00 CET to 03:00 CEST.
+//   - The matching times repeated by the wall clock (when the clock falls
+//     back) are executed only once, at their first occurrences. E.g. in
+//     Europe/Berlin, "30 2 * * *" is executed at 02:30 CEST, but not at
+//     02:30 CET, on the day when the clock falls back from 03:00 CEST to
+//     02:00 CET.
+func (s *Schedule) Next(prev time.Time) time.Time {
+	prev = prev.In(s.loc)
+
+	// Search the matching wall-clock times in UTC, which has no transitions,
+	// and return the first one that happens after prev in the location.
+	w := wallClock(prev)
+	for {
+		w = s.nextWallClock(w)
+		if w.IsZero() {
+			return w
+		}
+		if t := resolve(w, s.loc); t.After(prev) {
+			return t.UTC()
+		}
+	}
+}
+
+// nextWallClock returns the earliest wall-clock time after w which matches s.
+// Both w and the returned time represent wall-clock times in UTC.
+func (s *Schedule) nextWallClock(w time.Time) time.Time {
+	t := w.Add(time.Second)
+	yearLimit := t.Year() + searchYears
+
+	// Each field is advanced until it matches. Once a field is advanced,
+	// all the lower fields are reset to their minimums, and once a field
+	// wraps around, the search restarts from the highest field.
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for !has(s.month, int(t.Month())) {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !s.dayMatches(t) {
+		t = t.Truncate(24*time.Hour).AddDate(0, 0, 1)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for !has(s.hour, t.Hour()) {
+		t = t.Truncate(time.Hour).Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for !has(s.minute, t.Minute()) {
+		t = t.Truncate(time.Minute).Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	for !has(s.second, t.Second()) {
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+
+	return t
+}
+
+// resolve returns the moment at which the wall clock of loc shows w, which
+// is a wall-clock time represented in UTC.
+//
+// If w is skipped by the wall clock, resolve returns the moment of the
+// transition. If w is repeated by the wall clock, resolve returns its first
+// occurrence.
+func resolve(w time.Time, loc *time.Location) time.Time {
+	t := time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), w.Second(), 0, loc)
+	start, end := t.ZoneBounds()
+
+	if !sameWallClock(t, w) {
+		// w is skipped, in which case time.Date normalizes it into either
+		// the zone before the transition, or the one after.
+		if wallClock(t).Before(w) {
+			return end
+		}
+		return start
+	}
+
+	if !start.IsZero() {
+		// If the clock falls back at start, w may also occur before start.
+		_, offset := t.Zone()
+		_, prevOffset := start.Add(-1).Zone()
+		if prevOffset > offset {
+			earlier := t.Add(-time.Duration(prevOffset-offset) * time.Second)
+			if earlier.Before(start) && sameWallClock(earlier, w) {
+				return earlier
+			}
+		}
+	}
+	return t
+}
+
+// wallClock returns the wall-clock time of t represented in UTC.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// sameWallClock reports whether the wall clock of t shows w, which is a
+// wall-clock time represented in UTC.
+func sameWallClock(t, w time.Time) bool {
+	return wallClock(t).Equal(w)
+}
+
+// dayMatches reports whether the day of t matches s.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := has(s.dom, t.Day())
+	dowMatch := has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// has reports whether the i-th bit of bits is set.
+func has(bits uint64, i int) bool {
+	return bits&(1<<uint(i)) != 0
+}
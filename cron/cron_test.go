@@ -0,0 +1,298 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+	"github.com/RussellLuo/timingwheel/cron"
+)
+
+var _ timingwheel.ZonedScheduler = (*cron.Schedule)(nil)
+
+const layout = "2006-01-02 15:04:05 Mon"
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%q): %v", name, err)
+	}
+	return loc
+}
+
+func TestSchedule_Next(t *testing.T) {
+	cases := []struct {
+		spec string
+		from string // in UTC
+		want string // in UTC, or empty if there is no next time
+	}{
+		// Stars.
+		{"* * * * * *", "2020-01-01 00:00:00 Wed", "2020-01-01 00:00:01 Wed"},
+		{"* * * * *", "2020-01-01 00:00:00 Wed", "2020-01-01 00:01:00 Wed"},
+		{"? * * ? *", "2020-01-01 00:00:00 Wed", "2020-01-01 00:01:00 Wed"},
+		{"* * * * *", "2020-01-01 00:00:59 Wed", "2020-01-01 00:01:00 Wed"},
+		{"* * * * *", "2020-12-31 23:59:30 Thu", "2021-01-01 00:00:00 Fri"},
+
+		// Single values.
+		{"30 * * * * *", "2020-01-01 00:00:00 Wed", "2020-01-01 00:00:30 Wed"},
+		{"30 * * * * *", "2020-01-01 00:00:30 Wed", "2020-01-01 00:01:30 Wed"},
+		{"15 * * * *", "2020-01-01 00:20:00 Wed", "2020-01-01 01:15:00 Wed"},
+		{"0 9 * * *", "2020-01-01 09:00:00 Wed", "2020-01-02 09:00:00 Thu"},
+		{"0 9 * * *", "2020-01-01 08:59:59 Wed", "2020-01-01 09:00:00 Wed"},
+		{"0 0 15 * *", "2020-01-20 00:00:00 Mon", "2020-02-15 00:00:00 Sat"},
+		{"0 0 1 6 *", "2020-07-01 00:00:00 Wed", "2021-06-01 00:00:00 Tue"},
+		{"0 0 * * 1", "2020-01-01 00:00:00 Wed", "2020-01-06 00:00:00 Mon"},
+
+		// Ranges.
+		{"0 9-17 * * *", "2020-01-01 17:00:00 Wed", "2020-01-02 09:00:00 Thu"},
+		{"0 9-17 * * *", "2020-01-01 12:30:00 Wed", "2020-01-01 13:00:00 Wed"},
+		{"0 0 * * 1-5", "2020-01-03 00:00:00 Fri", "2020-01-06 00:00:00 Mon"},
+		{"0 0 * * 5-7", "2020-01-03 00:00:00 Fri", "2020-01-04 00:00:00 Sat"},
+		{"0 0 * * 6-7", "2020-01-04 00:00:00 Sat", "2020-01-05 00:00:00 Sun"},
+
+		// Steps.
+		{"*/15 * * * * *", "2020-01-01 00:00:00 Wed", "2020-01-01 00:00:15 Wed"},
+		{"*/15 * * * * *", "2020-01-01 00:00:45 Wed", "2020-01-01 00:01:00 Wed"},
+		{"*/7 * * * *", "2020-01-01 00:56:00 Wed", "2020-01-01 01:00:00 Wed"},
+		{"10-30/10 * * * *", "2020-01-01 00:30:00 Wed", "2020-01-01 01:10:00 Wed"},
+		{"5/20 * * * *", "2020-01-01 00:25:00 Wed", "2020-01-01 00:45:00 Wed"},
+		{"0 0 */2 * *", "2020-01-01 00:00:00 Wed", "2020-01-03 00:00:00 Fri"},
+		{"0 0 1 */3 *", "2020-01-01 00:00:00 Wed", "2020-04-01 00:00:00 Wed"},
+		{"0 0 * * */2", "2020-01-04 00:00:00 Sat", "2020-01-05 00:00:00 Sun"},
+		// Sunday (7) is not included unless written explicitly.
+		{"0 0 * * 1/2", "2020-01-03 00:00:00 Fri", "2020-01-06 00:00:00 Mon"},
+		{"0 0 * * 1-7/2", "2020-01-03 00:00:00 Fri", "2020-01-05 00:00:00 Sun"},
+		{"0 0 * * 5-7/1", "2020-01-04 00:00:00 Sat", "2020-01-05 00:00:00 Sun"},
+		{"0 0 * * 7", "2020-01-01 00:00:00 Wed", "2020-01-05 00:00:00 Sun"},
+
+		// Lists.
+		{"0 8,12,18 * * *", "2020-01-01 12:00:00 Wed", "2020-01-01 18:00:00 Wed"},
+		{"0 8,12,18 * * *", "2020-01-01 18:00:00 Wed", "2020-01-02 08:00:00 Thu"},
+		{"0,30 9-10 * * *", "2020-01-01 09:30:00 Wed", "2020-01-01 10:00:00 Wed"},
+		{"0 0 1,15 * *", "2020-01-01 00:00:00 Wed", "2020-01-15 00:00:00 Wed"},
+
+		// Names.
+		{"0 0 1 JAN,jul *", "2020-01-01 00:00:00 Wed", "2020-07-01 00:00:00 Wed"},
+		{"0 0 * * MON-FRI", "2020-01-04 00:00:00 Sat", "2020-01-06 00:00:00 Mon"},
+		{"0 0 * * sun", "2020-01-01 00:00:00 Wed", "2020-01-05 00:00:00 Sun"},
+		{"0 0 1 feb-apr *", "2020-02-01 00:00:00 Sat", "2020-03-01 00:00:00 Sun"},
+
+		// Day of month vs. day of week.
+		{"0 0 13 * 5", "2020-01-01 00:00:00 Wed", "2020-01-03 00:00:00 Fri"},
+		{"0 0 13 * 5", "2020-01-03 00:00:00 Fri", "2020-01-10 00:00:00 Fri"},
+		{"0 0 13 * 5", "2020-01-10 00:00:00 Fri", "2020-01-13 00:00:00 Mon"},
+		{"0 0 13 * *", "2020-01-10 00:00:00 Fri", "2020-01-13 00:00:00 Mon"},
+		// A field starting with a star is not restricted, thus both fields must match.
+		{"0 0 */10 * 5", "2020-01-01 00:00:00 Wed", "2020-01-31 00:00:00 Fri"},
+		{"0 0 * * 5", "2020-01-10 00:00:00 Fri", "2020-01-17 00:00:00 Fri"},
+
+		// Month ends and leap years.
+		{"0 0 31 * *", "2020-01-31 00:00:00 Fri", "2020-03-31 00:00:00 Tue"},
+		{"0 0 30 * *", "2020-01-30 00:00:00 Thu", "2020-03-30 00:00:00 Mon"},
+		{"0 0 29 2 *", "2020-03-01 00:00:00 Sun", "2024-02-29 00:00:00 Thu"},
+		{"0 0 29 2 *", "2019-01-01 00:00:00 Tue", "2020-02-29 00:00:00 Sat"},
+		{"0 0 30 2 *", "2020-01-01 00:00:00 Wed", ""},
+		{"0 0 31 4,6,9,11 *", "2020-01-01 00:00:00 Wed", ""},
+
+		// Macros.
+		{"@yearly", "2020-06-15 12:00:00 Mon", "2021-01-01 00:00:00 Fri"},
+		{"@annually", "2020-06-15 12:00:00 Mon", "2021-01-01 00:00:00 Fri"},
+		{"@monthly", "2020-06-15 12:00:00 Mon", "2020-07-01 00:00:00 Wed"},
+		{"@weekly", "2020-06-15 12:00:00 Mon", "2020-06-21 00:00:00 Sun"},
+		{"@daily", "2020-06-15 12:00:00 Mon", "2020-06-16 00:00:00 Tue"},
+		{"@midnight", "2020-06-15 12:00:00 Mon", "2020-06-16 00:00:00 Tue"},
+		{"@hourly", "2020-06-15 12:00:00 Mon", "2020-06-15 13:00:00 Mon"},
+		{"@HOURLY", "2020-06-15 12:59:59 Mon", "2020-06-15 13:00:00 Mon"},
+
+		// Time zones.
+		{"CRON_TZ=Asia/Tokyo 0 9 * * *", "2020-01-01 00:00:00 Wed", "2020-01-02 00:00:00 Thu"},
+		{"CRON_TZ=Asia/Tokyo 0 9 * * *", "2019-12-31 23:59:59 Tue", "2020-01-01 00:00:00 Wed"},
+		{"TZ=America/New_York 0 9 * * *", "2020-01-01 00:00:00 Wed", "2020-01-01 14:00:00 Wed"},
+		{"TZ=America/New_York 0 9 * * *", "2020-07-01 00:00:00 Wed", "2020-07-01 13:00:00 Wed"},
+		{"CRON_TZ=Asia/Kolkata 0 * * * *", "2020-01-01 00:00:00 Wed", "2020-01-01 00:30:00 Wed"},
+		{"CRON_TZ=Asia/Kolkata @daily", "2020-01-01 00:00:00 Wed", "2020-01-01 18:30:00 Wed"},
+	}
+
+	for _, c := range cases {
+		s, err := cron.ParseInLocation(c.spec, time.UTC)
+		if err != nil {
+			t.Errorf("ParseInLocation(%q): %v", c.spec, err)
+			continue
+		}
+
+		from, _ := time.Parse(layout, c.from)
+		got := s.Next(from)
+
+		var want time.Time
+		if c.want != "" {
+			want, _ = time.Parse(layout, c.want)
+		}
+		if !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("%q.Next(%s): Got (%s) != Want (%s)", c.spec, c.from, got.Format(layout), c.want)
+		}
+	}
+}
+
+func TestSchedule_Next_SubSecond(t *testing.T) {
+	s := cron.MustParse("* * * * * *")
+	from := time.Date(2020, 1, 1, 0, 0, 0, 500, time.UTC)
+	want := time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC)
+	if got := s.Next(from); !got.Equal(want) {
+		t.Fatalf("Got (%+v) != Want (%+v)", got, want)
+	}
+}
+
+func TestParseInLocation(t *testing.T) {
+	tokyo := mustLoad(t, "Asia/Tokyo")
+
+	s, err := cron.ParseInLocation("0 9 * * *", tokyo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Location() != tokyo || s.String() != "0 9 * * *" {
+		t.Fatalf("Got (%+v, %+v) != Want (%+v, %+v)", s.Location(), s.String(), tokyo, "0 9 * * *")
+	}
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	if got := s.Next(from); !got.Equal(want) {
+		t.Fatalf("Got (%+v) != Want (%+v)", got, want)
+	}
+
+	// The time zone in the expression takes precedence.
+	s, err = cron.ParseInLocation("CRON_TZ=UTC 0 9 * * *", tokyo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Location() != time.UTC {
+		t.Fatalf("Got (%+v) != Want (%+v)", s.Location(), time.UTC)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	specs := []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* * * * -1",
+		"* * * * 7/2",
+		"a * * * *",
+		"* * * foo *",
+		"* * * * mon-",
+		"5-1 * * * *",
+		"1-2-3 * * * *",
+		"*/0 * * * *",
+		"*/-1 * * * *",
+		"*/x * * * *",
+		"1/2/3 * * * *",
+		"1,,2 * * * *",
+		"@every",
+		"@reboot",
+		"CRON_TZ=Nowhere/Nothing * * * * *",
+		"CRON_TZ=UTC",
+	}
+	for _, spec := range specs {
+		if _, err := cron.Parse(spec); err == nil {
+			t.Errorf("Parse(%q): Got no error", spec)
+		}
+	}
+
+	if _, err := cron.ParseInLocation("* * * * *", nil); err == nil {
+		t.Errorf("ParseInLocation(nil location): Got no error")
+	}
+}
+
+func TestMustParse(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("MustParse did not panic")
+		}
+	}()
+	cron.MustParse("bad")
+}
+
+func TestSchedule_ScheduleFunc(t *testing.T) {
+	clock := timingwheel.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tw := timingwheel.NewTimingWheel(time.Millisecond, 20,
+		timingwheel.WithClock(clock),
+		timingwheel.WithInlineTasks(),
+	)
+	tw.Start()
+	defer tw.Stop()
+
+	var fired []time.Time
+	timer := tw.ScheduleFunc(cron.MustParse("CRON_TZ=UTC */15 * * * *"), func() {
+		fired = append(fired, clock.Now())
+	})
+	defer timer.Stop()
+
+	clock.Advance(time.Hour)
+
+	if len(fired) != 4 {
+		t.Fatalf("Got (%+v) executions != Want (%+v)", len(fired), 4)
+	}
+	for i, f := range fired {
+		want := time.Date(2020, 1, 1, 0, 15*(i+1), 0, 0, time.UTC)
+		if !f.Equal(want) {
+			t.Errorf("Execution %d: Got (%+v) != Want (%+v)", i, f, want)
+		}
+	}
+}
+
+func TestSchedule_Next_DST(t *testing.T) {
+	const layout = "2006-01-02 15:04:05"
+
+	cases := []struct {
+		spec string
+		from string // in UTC
+		want string // in UTC
+	}{
+		// Europe/Berlin springs forward from 02:00 CET (+01:00) to 03:00 CEST
+		// (+02:00) at 2021-03-28 01:00:00 UTC.
+		{"CRON_TZ=Europe/Berlin 30 2 * * *", "2021-03-27 00:00:00", "2021-03-27 01:30:00"},
+		{"CRON_TZ=Europe/Berlin 30 2 * * *", "2021-03-27 01:30:00", "2021-03-28 01:00:00"}, // skipped
+		{"CRON_TZ=Europe/Berlin 30 2 * * *", "2021-03-28 01:00:00", "2021-03-29 00:30:00"},
+		{"CRON_TZ=Europe/Berlin 0 2,3 * * *", "2021-03-28 00:00:00", "2021-03-28 01:00:00"},
+		{"CRON_TZ=Europe/Berlin 0 2,3 * * *", "2021-03-28 01:00:00", "2021-03-29 00:00:00"}, // merged
+		{"CRON_TZ=Europe/Berlin */30 * * * *", "2021-03-28 00:30:00", "2021-03-28 01:00:00"},
+		{"CRON_TZ=Europe/Berlin */30 * * * *", "2021-03-28 01:00:00", "2021-03-28 01:30:00"},
+		{"CRON_TZ=Europe/Berlin 0 9 * * *", "2021-03-27 08:00:00", "2021-03-28 07:00:00"},
+
+		// Europe/Berlin falls back from 03:00 CEST (+02:00) to 02:00 CET
+		// (+01:00) at 2021-10-31 01:00:00 UTC.
+		{"CRON_TZ=Europe/Berlin 30 2 * * *", "2021-10-30 23:00:00", "2021-10-31 00:30:00"},
+		{"CRON_TZ=Europe/Berlin 30 2 * * *", "2021-10-31 00:30:00", "2021-11-01 01:30:00"}, // not repeated
+		{"CRON_TZ=Europe/Berlin */30 * * * *", "2021-10-31 00:30:00", "2021-10-31 02:00:00"},
+		{"CRON_TZ=Europe/Berlin */30 * * * *", "2021-10-31 01:15:00", "2021-10-31 02:00:00"},
+		{"CRON_TZ=Europe/Berlin * * * * * *", "2021-10-31 00:59:59", "2021-10-31 02:00:00"},
+		{"CRON_TZ=Europe/Berlin 0 9 * * *", "2021-10-30 07:00:00", "2021-10-31 08:00:00"},
+
+		// America/New_York springs forward from 02:00 EST (-05:00) to 03:00 EDT
+		// (-04:00) at 2021-03-14 07:00:00 UTC, and falls back from 02:00 EDT to
+		// 01:00 EST at 2021-11-07 06:00:00 UTC.
+		{"CRON_TZ=America/New_York 30 2 * * *", "2021-03-13 08:00:00", "2021-03-14 07:00:00"},
+		{"CRON_TZ=America/New_York 30 1 * * *", "2021-11-06 06:00:00", "2021-11-07 05:30:00"},
+		{"CRON_TZ=America/New_York 30 1 * * *", "2021-11-07 05:30:00", "2021-11-08 06:30:00"},
+	}
+
+	for _, c := range cases {
+		s, err := cron.Parse(c.spec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", c.spec, err)
+			continue
+		}
+
+		from, _ := time.Parse(layout, c.from)
+		want, _ := time.Parse(layout, c.want)
+		if got := s.Next(from); !got.Equal(want) {
+			t.Errorf("%q.Next(%s): Got (%s) != Want (%s)", c.spec, c.from, got.Format(layout), c.want)
+		}
+	}
+}
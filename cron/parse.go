@@ -0,0 +1,259 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bounds are the bounds of a field, along with the names of its values.
+type bounds struct {
+	name     string
+	min, max int
+	names    map[string]int
+
+	// If not zero, stepMax is the maximum of an implicit range (i.e. "*"
+	// or "a/n") with a step, which excludes the values that are only
+	// aliases.
+	stepMax int
+}
+
+var (
+	seconds = bounds{name: "second", min: 0, max: 59}
+	minutes = bounds{name: "minute", min: 0, max: 59}
+	hours   = bounds{name: "hour", min: 0, max: 23}
+	dom     = bounds{name: "day of month", min: 1, max: 31}
+	months  = bounds{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Both 0 and 7 stand for Sunday, but 7 is never reached by a step
+	// through an implicit range.
+	dow = bounds{name: "day of week", min: 0, max: 7, stepMax: 6, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// macros are the predefined schedules.
+var macros = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// Parse parses a cron expression in the local time zone. See ParseInLocation.
+func Parse(spec string) (*Schedule, error) {
+	return ParseInLocation(spec, time.Local)
+}
+
+// MustParse is like Parse but panics if the expression cannot be parsed.
+func MustParse(spec string) *Schedule {
+	s, err := Parse(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// ParseInLocation parses a cron expression, which is interpreted in the
+// time zone loc, unless the expression specifies its own time zone.
+//
+// The expression consists of either 5 fields:
+//
+//	minute hour day-of-month month day-of-week
+//
+// or 6 fields, with the leading one being the seconds:
+//
+//	second minute hour day-of-month month day-of-week
+//
+// Each field is a comma-separated list of items, and each item is one of:
+//
+//   - "*" for all the values of the field ("?" is an alias)
+//   - "a" for the single value a
+//   - "a-b" for the values from a to b inclusively
+//   - "*/n" for every n-th value of the field, starting from the minimum
+//   - "a-b/n" for every n-th value from a to b
+//   - "a/n" for every n-th value from a to the maximum
+//
+// Months and days of week can also be written as the first three letters
+// of their English names (case-insensitive), e.g. "JAN" or "mon". Both 0
+// and 7 stand for Sunday, but "*/n" and "a/n" end at 6 (Saturday) for days
+// of week, e.g. "1/2" means Monday, Wednesday and Friday, while "1-7/2"
+// also includes Sunday. An item that matches no values, e.g. "7/2" for
+// days of week, is an error.
+//
+// As in the standard cron, if both the day-of-month and day-of-week fields
+// are restricted (i.e. do not start with "*" or "?"), a day matches if
+// either field matches.
+//
+// The expression can also be one of the macros: @yearly (or @annually),
+// @monthly, @weekly, @daily (or @midnight) and @hourly.
+//
+// The time zone can be specified by prefixing the expression with
+// "CRON_TZ=<zone> " (or "TZ=<zone> "), e.g. "CRON_TZ=Asia/Tokyo 0 9 * * *".
+func ParseInLocation(spec string, loc *time.Location) (*Schedule, error) {
+	if loc == nil {
+		return nil, errors.New("cron: nil location")
+	}
+
+	s := &Schedule{spec: spec, loc: loc}
+
+	expr := strings.TrimSpace(spec)
+	if strings.HasPrefix(expr, "CRON_TZ=") || strings.HasPrefix(expr, "TZ=") {
+		i := strings.IndexAny(expr, " \t")
+		if i == -1 {
+			return nil, fmt.Errorf("cron: missing fields after time zone in %q", spec)
+		}
+		name := expr[strings.Index(expr, "=")+1 : i]
+		l, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("cron: bad time zone %q: %v", name, err)
+		}
+		s.loc = l
+		expr = strings.TrimSpace(expr[i:])
+	}
+
+	if strings.HasPrefix(expr, "@") {
+		m, ok := macros[strings.ToLower(expr)]
+		if !ok {
+			return nil, fmt.Errorf("cron: unknown macro %q", expr)
+		}
+		expr = m
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron: expected 5 or 6 fields, found %d in %q", len(fields), spec)
+	}
+
+	var err error
+	if s.second, _, err = parseField(fields[0], seconds); err != nil {
+		return nil, err
+	}
+	if s.minute, _, err = parseField(fields[1], minutes); err != nil {
+		return nil, err
+	}
+	if s.hour, _, err = parseField(fields[2], hours); err != nil {
+		return nil, err
+	}
+	if s.dom, s.domStar, err = parseField(fields[3], dom); err != nil {
+		return nil, err
+	}
+	if s.month, _, err = parseField(fields[4], months); err != nil {
+		return nil, err
+	}
+	if s.dow, s.dowStar, err = parseField(fields[5], dow); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 {
+		// 7 is an alias of Sunday.
+		s.dow |= 1 << 0
+	}
+
+	return s, nil
+}
+
+// parseField parses a field into a bit set, in which the i-th bit is set
+// if the value i is allowed. It also reports whether the field starts with
+// a star.
+func parseField(field string, b bounds) (bits uint64, star bool, err error) {
+	star = strings.HasPrefix(field, "*") || strings.HasPrefix(field, "?")
+	for _, item := range strings.Split(field, ",") {
+		bs, err := parseItem(item, b)
+		if err != nil {
+			return 0, false, err
+		}
+		bits |= bs
+	}
+	return bits, star, nil
+}
+
+// parseItem parses an item of a field into a bit set.
+func parseItem(item string, b bounds) (uint64, error) {
+	rangeAndStep := strings.Split(item, "/")
+	if len(rangeAndStep) > 2 {
+		return 0, fmt.Errorf("cron: bad %s %q", b.name, item)
+	}
+
+	var start, end int
+	implicit := false // whether the end of the range is implicit
+	rng := rangeAndStep[0]
+	switch {
+	case rng == "*" || rng == "?":
+		start, end = b.min, b.max
+		implicit = true
+	case strings.Contains(rng, "-"):
+		startAndEnd := strings.Split(rng, "-")
+		if len(startAndEnd) != 2 {
+			return 0, fmt.Errorf("cron: bad %s range %q", b.name, item)
+		}
+		var err error
+		if start, err = parseValue(startAndEnd[0], b); err != nil {
+			return 0, err
+		}
+		if end, err = parseValue(startAndEnd[1], b); err != nil {
+			return 0, err
+		}
+	default:
+		var err error
+		if start, err = parseValue(rng, b); err != nil {
+			return 0, err
+		}
+		end = start
+		if len(rangeAndStep) == 2 {
+			// "a/n" means every n-th value from a to the maximum.
+			end = b.max
+			implicit = true
+		}
+	}
+	if start > end {
+		return 0, fmt.Errorf("cron: bad %s range %q: %d > %d", b.name, item, start, end)
+	}
+
+	step := 1
+	if len(rangeAndStep) == 2 {
+		var err error
+		if step, err = strconv.Atoi(rangeAndStep[1]); err != nil || step <= 0 {
+			return 0, fmt.Errorf("cron: bad %s step %q", b.name, item)
+		}
+	}
+
+	if len(rangeAndStep) == 2 && implicit && b.stepMax != 0 && end > b.stepMax {
+		end = b.stepMax
+	}
+
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << uint(i)
+	}
+	if bits == 0 {
+		return 0, fmt.Errorf("cron: %s %q matches no values", b.name, item)
+	}
+	return bits, nil
+}
+
+// parseValue parses a single value, which is either a number or a name.
+func parseValue(s string, b bounds) (int, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("cron: bad %s %q", b.name, s)
+	}
+	if v < b.min || v > b.max {
+		return 0, fmt.Errorf("cron: %s %d out of range [%d, %d]", b.name, v, b.min, b.max)
+	}
+	return v, nil
+}
@@ -122,10 +122,96 @@ func (dq *DelayQueue) Offer(elem interface{}, expiration int64) {
 	}
 }
 
+// Timer is a single-use timer, which is used by PollFunc to wait for the
+// earliest element to expire.
+type Timer interface {
+	// C returns the channel on which the current time is delivered
+	// when the timer expires.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing.
+	Stop() bool
+}
+
+// stdTimer adapts *time.Timer to Timer.
+type stdTimer struct {
+	t *time.Timer
+}
+
+func (t stdTimer) C() <-chan time.Time { return t.t.C }
+func (t stdTimer) Stop() bool          { return t.t.Stop() }
+
+// newMsTimer creates a Timer that fires after delta milliseconds.
+func newMsTimer(delta int64) Timer {
+	return stdTimer{time.NewTimer(time.Duration(delta) * time.Millisecond)}
+}
+
+// Peek returns the expiration of the earliest element in the current queue.
+// It returns false if the queue is empty.
+func (dq *DelayQueue) Peek() (int64, bool) {
+	dq.mu.Lock()
+	defer dq.mu.Unlock()
+
+	if dq.pq.Len() == 0 {
+		return 0, false
+	}
+	return dq.pq[0].Priority, true
+}
+
+// Len returns the number of elements in the current queue.
+func (dq *DelayQueue) Len() int {
+	dq.mu.Lock()
+	defer dq.mu.Unlock()
+	return dq.pq.Len()
+}
+
+// Shift removes and returns the earliest element in the current queue if
+// its expiration is not after now. Otherwise, it returns false.
+//
+// Shift is intended for driving the queue manually, and should not be
+// used together with Poll.
+func (dq *DelayQueue) Shift(now int64) (interface{}, bool) {
+	dq.mu.Lock()
+	defer dq.mu.Unlock()
+
+	item, _ := dq.pq.PeekAndShift(now)
+	if item == nil {
+		return nil, false
+	}
+	return item.Value, true
+}
+
 // Poll starts an infinite loop, in which it continually waits for an element
 // to expire and then send the expired element to the channel C.
+//
+// The expirations of the elements, as well as the result of nowF, are
+// interpreted in milliseconds.
 func (dq *DelayQueue) Poll(exitC chan struct{}, nowF func() int64) {
+	dq.PollFunc(exitC, nowF, newMsTimer, func(elem interface{}) {
+		select {
+		case dq.C <- elem:
+			// The expired element has been sent out successfully.
+		case <-exitC:
+		}
+	})
+}
+
+// PollFunc is like Poll, except that each expired element is passed to f,
+// which is called synchronously in the polling goroutine, instead of being
+// sent to the channel C.
+//
+// Whenever PollFunc needs to wait for the earliest element to expire, it
+// calls newTimerF with the delay, which is in the same unit as the expirations.
+//
+// It is safe to call Offer from within f.
+func (dq *DelayQueue) PollFunc(exitC chan struct{}, nowF func() int64, newTimerF func(delta int64) Timer, f func(elem interface{})) {
 	for {
+		select {
+		case <-exitC:
+			goto exit
+		default:
+		}
+
 		now := nowF()
 
 		dq.mu.Lock()
@@ -152,11 +238,13 @@ func (dq *DelayQueue) Poll(exitC chan struct{}, nowF func() int64) {
 				}
 			} else if delta > 0 {
 				// At least one item is pending.
+				timer := newTimerF(delta)
 				select {
 				case <-dq.wakeupC:
 					// A new item with an "earlier" expiration than the current "earliest" one is added.
+					timer.Stop()
 					continue
-				case <-time.After(time.Duration(delta) * time.Millisecond):
+				case <-timer.C():
 					// The current "earliest" item expires.
 
 					// Reset the sleeping state since there's no need to receive from wakeupC.
@@ -167,17 +255,15 @@ func (dq *DelayQueue) Poll(exitC chan struct{}, nowF func() int64) {
 					}
 					continue
 				case <-exitC:
+					timer.Stop()
 					goto exit
 				}
 			}
 		}
 
-		select {
-		case dq.C <- item.Value:
-			// The expired element has been sent out successfully.
-		case <-exitC:
-			goto exit
-		}
+		// Since the sleeping state is always reset before reaching here,
+		// any Offer() called by f will not block on sending to wakeupC.
+		f(item.Value)
 	}
 
 exit:
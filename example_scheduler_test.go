@@ -30,8 +30,7 @@ func Example_scheduleTimer() {
 	<-exitC
 
 	// We need to stop the timer since it will be restarted again and again.
-	for !t.Stop() {
-	}
+	t.Stop()
 
 	// Output:
 	// The timer fires
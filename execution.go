@@ -0,0 +1,42 @@
+package timingwheel
+
+import (
+	"context"
+	"time"
+)
+
+// Execution describes an execution of a timer's task.
+type Execution struct {
+	// Scheduled is the time at which the task was scheduled to run, i.e.
+	// the time at which the timer expired.
+	Scheduled time.Time
+
+	// Started is the time, of the timing wheel's clock, at which the task
+	// was started. Thus Started.Sub(Scheduled) is the lateness of the task.
+	Started time.Time
+
+	// Missed is the number of the executions that have been skipped, due
+	// to the misfire policy or NoOverlap, since the previous execution.
+	Missed int
+
+	// Rescheduled tells whether the timer had been rescheduled for another
+	// execution when the task was started. It is always false for one-shot
+	// timers and fixed-delay timers, the latter of which are rescheduled
+	// only after their tasks return.
+	Rescheduled bool
+}
+
+type executionKey struct{}
+
+// ExecutionFromContext returns the execution described by ctx. It must be
+// called with the context passed to the task of AfterFuncContext or
+// ScheduleFuncContext, and it returns false for any other context.
+func ExecutionFromContext(ctx context.Context) (Execution, bool) {
+	e, ok := ctx.Value(executionKey{}).(Execution)
+	return e, ok
+}
+
+// withExecution returns a copy of ctx carrying the execution e.
+func withExecution(ctx context.Context, e Execution) context.Context {
+	return context.WithValue(ctx, executionKey{}, e)
+}
@@ -0,0 +1,168 @@
+package timingwheel
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Executor executes the tasks of the expired timers.
+type Executor interface {
+	// Execute arranges for task to be executed, typically in another
+	// goroutine. It returns false if task is rejected, in which case task
+	// will never be executed.
+	//
+	// Execute is called in the timing wheel's goroutine, thus it should
+	// return quickly.
+	Execute(task func()) bool
+}
+
+// goExecutor executes each task in its own goroutine.
+type goExecutor struct{}
+
+func (goExecutor) Execute(task func()) bool {
+	go task()
+	return true
+}
+
+// SaturationPolicy determines what a WorkerPool does with a new task
+// when its queue is full.
+type SaturationPolicy int
+
+const (
+	// Block blocks the caller until there is room in the queue. Note that
+	// the caller is typically the timing wheel's goroutine, which will not
+	// fire any other timers while being blocked.
+	Block SaturationPolicy = iota
+
+	// Drop rejects the new task.
+	Drop
+
+	// CallerRuns executes the new task in the caller's goroutine.
+	CallerRuns
+)
+
+// WorkerPoolStats holds the statistics of a WorkerPool.
+type WorkerPoolStats struct {
+	Workers    int    // The number of workers.
+	Busy       int    // The number of workers that are executing tasks.
+	Queued     int    // The number of tasks waiting in the queue.
+	Submitted  uint64 // The total number of tasks submitted.
+	Completed  uint64 // The total number of tasks completed.
+	Dropped    uint64 // The total number of tasks rejected due to saturation.
+	CallerRuns uint64 // The total number of tasks executed by the callers due to saturation.
+}
+
+// WorkerPool is an Executor that executes tasks with a bounded number
+// of workers, to prevent a burst of expired timers from starting too
+// many goroutines at once.
+type WorkerPool struct {
+	// 64-bit atomic operations require 64-bit alignment, but 32-bit
+	// compilers do not ensure it. So we must keep the 64-bit fields
+	// as the first fields of the struct.
+	submitted  uint64
+	completed  uint64
+	dropped    uint64
+	callerRuns uint64
+	busy       int64
+
+	workers int
+	policy  SaturationPolicy
+	queue   chan func()
+
+	mu        sync.RWMutex // protects closed and sending to queue
+	closed    bool
+	waitGroup sync.WaitGroup
+}
+
+// NewWorkerPool creates an instance of WorkerPool with the given number of
+// workers and queue size, whose saturation policy is policy.
+func NewWorkerPool(workers, queueSize int, policy SaturationPolicy) *WorkerPool {
+	if workers <= 0 {
+		panic(errors.New("workers must be greater than 0"))
+	}
+	if queueSize < 0 {
+		panic(errors.New("queueSize must be greater than or equal to 0"))
+	}
+
+	p := &WorkerPool{
+		workers: workers,
+		policy:  policy,
+		queue:   make(chan func(), queueSize),
+	}
+	for i := 0; i < workers; i++ {
+		p.waitGroup.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+func (p *WorkerPool) work() {
+	defer p.waitGroup.Done()
+	for task := range p.queue {
+		atomic.AddInt64(&p.busy, 1)
+		task()
+		atomic.AddInt64(&p.busy, -1)
+		atomic.AddUint64(&p.completed, 1)
+	}
+}
+
+// Execute submits task to the pool. If the queue is full, the behavior
+// depends on the saturation policy of the pool. It returns false if task
+// is dropped, or if the pool has been closed.
+func (p *WorkerPool) Execute(task func()) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return false
+	}
+	atomic.AddUint64(&p.submitted, 1)
+
+	select {
+	case p.queue <- task:
+		return true
+	default:
+	}
+
+	// The queue is full.
+	switch p.policy {
+	case Drop:
+		atomic.AddUint64(&p.dropped, 1)
+		return false
+	case CallerRuns:
+		atomic.AddUint64(&p.callerRuns, 1)
+		task()
+		atomic.AddUint64(&p.completed, 1)
+		return true
+	default:
+		p.queue <- task
+		return true
+	}
+}
+
+// Stats returns the current statistics of the pool.
+func (p *WorkerPool) Stats() WorkerPoolStats {
+	return WorkerPoolStats{
+		Workers:    p.workers,
+		Busy:       int(atomic.LoadInt64(&p.busy)),
+		Queued:     len(p.queue),
+		Submitted:  atomic.LoadUint64(&p.submitted),
+		Completed:  atomic.LoadUint64(&p.completed),
+		Dropped:    atomic.LoadUint64(&p.dropped),
+		CallerRuns: atomic.LoadUint64(&p.callerRuns),
+	}
+}
+
+// Close stops accepting new tasks, and waits for all the queued tasks
+// to complete.
+func (p *WorkerPool) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	p.waitGroup.Wait()
+}
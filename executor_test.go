@@ -0,0 +1,123 @@
+package timingwheel_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+)
+
+// newSaturatedPool creates a WorkerPool, whose only worker is occupied and
+// whose queue is full, until the test finishes.
+func newSaturatedPool(t testing.TB, policy timingwheel.SaturationPolicy) *timingwheel.WorkerPool {
+	p := timingwheel.NewWorkerPool(1, 1, policy)
+	startedC := make(chan struct{})
+	releaseC := make(chan struct{})
+	t.Cleanup(func() {
+		close(releaseC)
+		p.Close()
+	})
+
+	p.Execute(func() {
+		close(startedC)
+		<-releaseC
+	})
+	<-startedC
+	p.Execute(func() {})
+	return p
+}
+
+func TestWorkerPool_SaturationPolicy(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy timingwheel.SaturationPolicy
+		want   timingwheel.WorkerPoolStats
+	}{
+		{
+			name:   "drop",
+			policy: timingwheel.Drop,
+			want:   timingwheel.WorkerPoolStats{Workers: 1, Submitted: 3, Completed: 2, Dropped: 1},
+		},
+		{
+			name:   "caller runs",
+			policy: timingwheel.CallerRuns,
+			want:   timingwheel.WorkerPoolStats{Workers: 1, Submitted: 3, Completed: 3, CallerRuns: 1},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := timingwheel.NewWorkerPool(1, 1, c.policy)
+
+			// Occupy the only worker.
+			startedC := make(chan struct{})
+			releaseC := make(chan struct{})
+			p.Execute(func() {
+				close(startedC)
+				<-releaseC
+			})
+			<-startedC
+
+			// Fill up the queue.
+			p.Execute(func() {})
+
+			callerRan := false
+			ok := p.Execute(func() { callerRan = true })
+			if ok != (c.policy == timingwheel.CallerRuns) || callerRan != ok {
+				t.Fatalf("Execute on saturation: got (ok: %v, callerRan: %v)", ok, callerRan)
+			}
+
+			close(releaseC)
+			p.Close()
+
+			if got := p.Stats(); got != c.want {
+				t.Fatalf("Stats: Got (%+v) != Want (%+v)", got, c.want)
+			}
+		})
+	}
+}
+
+func TestWorkerPool_Block(t *testing.T) {
+	p := timingwheel.NewWorkerPool(1, 0, timingwheel.Block)
+	defer p.Close()
+
+	releaseC := make(chan struct{})
+	p.Execute(func() { <-releaseC })
+
+	doneC := make(chan struct{})
+	go func() {
+		p.Execute(func() {})
+		close(doneC)
+	}()
+
+	select {
+	case <-doneC:
+		t.Fatal("Execute did not block on saturation")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(releaseC)
+	<-doneC
+}
+
+func TestTimingWheel_WithExecutor(t *testing.T) {
+	p := timingwheel.NewWorkerPool(4, 100, timingwheel.Block)
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), timingwheel.WithExecutor(p))
+
+	const n = 50
+	exitC := make(chan struct{}, n)
+	for i := 0; i < n; i++ {
+		tw.AfterFunc(10*time.Millisecond, func() {
+			exitC <- struct{}{}
+		})
+	}
+
+	clock.Advance(10 * time.Millisecond)
+	for i := 0; i < n; i++ {
+		<-exitC
+	}
+
+	p.Close()
+	if got := p.Stats(); got.Submitted != n || got.Completed != n {
+		t.Fatalf("Stats: Got (%+v)", got)
+	}
+}
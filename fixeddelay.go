@@ -0,0 +1,111 @@
+package timingwheel
+
+import (
+	"sync/atomic"
+)
+
+// FixedDelay makes a recurring timer (e.g. the one created by ScheduleFunc)
+// ask its scheduler for the next execution time only after the task returns,
+// given the time at which the task returns, instead of before the task is
+// called, given the previous execution time. Thus the executions of the task
+// never overlap, and, for example, Every(d) of the schedulers subpackage
+// makes the task be called with a fixed delay d between the end of one
+// execution and the start of the next.
+//
+// While the task is running, the timer is not in the timing wheel, but it
+// is still considered active: Stop terminates the execution plan and returns
+// true, and Reset schedules the next execution at the new expiration, or as
+// soon as the task returns, whichever is later. However, if the timer is
+// stopped and then reset while the task is running, the next execution may
+// overlap the running one, since the timer has been restarted as a new one.
+//
+// Since the next execution time is always in the future, the misfire policy
+// has no effect on a fixed-delay timer.
+//
+// FixedDelay has no effect on a one-shot timer (e.g. the one created by
+// AfterFunc).
+func FixedDelay() TimerOption {
+	return func(t *Timer) {
+		t.fixedDelay = true
+	}
+}
+
+// NoOverlap makes a recurring timer skip an execution of its task if the
+// previous one is still running, which is useful for the tasks that may
+// occasionally take longer than the interval of their execution plans. The
+// skipped executions are counted as missed, see MissedRuns.
+func NoOverlap() TimerOption {
+	return func(t *Timer) {
+		t.noOverlap = true
+	}
+}
+
+// delayReschedule makes the recurring timer t, created for the scheduler s,
+// be rescheduled after its task returns.
+func (tw *TimingWheel) delayReschedule(t *Timer, s Scheduler) {
+	t.rearm = func() {
+		var buf [2]timerEvent
+
+		t.mu.Lock()
+		resetting := t.resetting
+		t.resetting = false
+		if !atomic.CompareAndSwapInt32(&t.delayed, 1, 0) {
+			// The timer has been stopped during the execution.
+			t.mu.Unlock()
+			return
+		}
+		next := t.Expiration()
+		if !resetting {
+			next = s.Next(schedulerTime(s, tw.clock.Now()))
+		}
+		var events []timerEvent
+		if !next.IsZero() {
+			// Delay the next execution to the next tick at least, so that
+			// an inline task will not be called recursively by rearm.
+			expiration := timeToNs(next)
+			if earliest := atomic.LoadInt64(&tw.currentTime) + tw.tick; expiration < earliest {
+				expiration = earliest
+			}
+			t.setExpiration(expiration)
+			events = tw.schedule(t, buf[:0])
+		}
+		t.mu.Unlock()
+
+		// Handle the events of t after t.mu is released. Note that t may still
+		// expire if the wheel has advanced meanwhile.
+		tw.runEvents(events)
+
+		if !t.pending() && t.release != nil {
+			// The execution plan ends, or the timing wheel has been shut down.
+			t.release()
+		}
+	}
+}
+
+// pending reports whether the timer t is in the timing wheel, or is a
+// fixed-delay timer waiting for its task to return.
+func (t *Timer) pending() bool {
+	return t.getBucket() != nil || atomic.LoadInt32(&t.delayed) == 1
+}
+
+// call calls the task of t for the execution e, with missed executions
+// skipped before the call.
+func (t *Timer) call(e Execution) {
+	if t.noOverlap {
+		if !atomic.CompareAndSwapInt32(&t.running, 0, 1) {
+			// The previous execution is still running, skip this one.
+			atomic.AddInt32(&t.missed, int32(e.Missed)+1)
+			return
+		}
+		defer atomic.StoreInt32(&t.running, 0)
+	}
+	if t.rearm != nil {
+		defer t.rearm()
+	}
+
+	if t.execTask != nil {
+		t.execTask(e)
+		return
+	}
+	t.task()
+}
@@ -0,0 +1,205 @@
+package timingwheel_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+)
+
+// taskDoneObserver notifies when a task returns.
+type taskDoneObserver struct {
+	done chan struct{}
+}
+
+func (o *taskDoneObserver) OnAdd(t *timingwheel.Timer, now time.Time)     {}
+func (o *taskDoneObserver) OnStop(t *timingwheel.Timer, now time.Time)    {}
+func (o *taskDoneObserver) OnCascade(t *timingwheel.Timer, now time.Time) {}
+func (o *taskDoneObserver) OnExpire(t *timingwheel.Timer, now time.Time)  {}
+func (o *taskDoneObserver) OnTaskDone(t *timingwheel.Timer, start, end time.Time) {
+	o.done <- struct{}{}
+}
+
+func TestTimingWheel_ScheduleFunc_FixedDelay(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	o := &taskDoneObserver{done: make(chan struct{}, 1)}
+	clock, tw := newFakeWheel(t, start, timingwheel.WithObserver(o))
+
+	startedC := make(chan time.Duration)
+	proceedC := make(chan struct{})
+	s := &scheduler{intervals: []time.Duration{time.Second, time.Second, time.Second}}
+	timer := tw.ScheduleFunc(s, func() {
+		startedC <- clock.Now().Sub(start)
+		<-proceedC
+	}, timingwheel.FixedDelay())
+
+	var got []time.Duration
+
+	// The first execution takes 2.5s.
+	clock.Advance(time.Second)
+	got = append(got, <-startedC)
+	clock.Advance(2500 * time.Millisecond)
+	proceedC <- struct{}{}
+	<-o.done
+
+	// The next execution is 1s after the previous one returns.
+	clock.Advance(time.Second)
+	got = append(got, <-startedC)
+
+	// Stop the timer while its task is running.
+	if !timer.Stop() {
+		t.Fatalf("Stop: Got (%+v) != Want (%+v)", false, true)
+	}
+	proceedC <- struct{}{}
+	<-o.done
+
+	clock.Advance(10 * time.Second)
+
+	want := []time.Duration{time.Second, 4500 * time.Millisecond}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got (%+v) != Want (%+v)", got, want)
+	}
+	if timer.Stop() {
+		t.Fatalf("Stop: Got (%+v) != Want (%+v)", true, false)
+	}
+}
+
+func TestTimingWheel_ScheduleFunc_FixedDelay_End(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), timingwheel.WithInlineTasks())
+
+	// The next execution would be due as soon as the task returns.
+	s := &scheduler{intervals: []time.Duration{time.Second, 0, 0}}
+	fired := 0
+	g := tw.NewTimerGroup()
+	g.ScheduleFunc(s, func() {
+		fired++
+	}, timingwheel.FixedDelay())
+
+	clock.Advance(time.Second)
+	if fired != 1 {
+		t.Fatalf("Got (%+v) != Want (%+v)", fired, 1)
+	}
+	if n := g.Len(); n != 1 {
+		t.Fatalf("Len: Got (%+v) != Want (%+v)", n, 1)
+	}
+
+	clock.Advance(time.Second)
+	if fired != 3 {
+		t.Fatalf("Got (%+v) != Want (%+v)", fired, 3)
+	}
+	if n := g.Len(); n != 0 {
+		t.Fatalf("Len: Got (%+v) != Want (%+v)", n, 0)
+	}
+}
+
+func TestTimingWheel_ScheduleFunc_FixedDelay_Reset(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	o := &taskDoneObserver{done: make(chan struct{}, 1)}
+	clock, tw := newFakeWheel(t, start, timingwheel.WithObserver(o))
+
+	startedC := make(chan time.Duration)
+	proceedC := make(chan struct{})
+	s := &scheduler{intervals: []time.Duration{time.Second, time.Second}}
+	timer := tw.ScheduleFunc(s, func() {
+		startedC <- clock.Now().Sub(start)
+		<-proceedC
+	}, timingwheel.FixedDelay())
+
+	var got []time.Duration
+
+	// Reset the timer while its task is running.
+	clock.Advance(time.Second)
+	got = append(got, <-startedC)
+	if !timer.Reset(500 * time.Millisecond) {
+		t.Fatalf("Reset: Got (%+v) != Want (%+v)", false, true)
+	}
+
+	// The new expiration has passed, but the task is still running.
+	clock.Advance(time.Second)
+	if n := tw.Stats().Fired; n != 1 {
+		t.Fatalf("Fired: Got (%+v) != Want (%+v)", n, 1)
+	}
+
+	// The next execution is as soon as the task returns.
+	proceedC <- struct{}{}
+	<-o.done
+	clock.Advance(time.Millisecond)
+	got = append(got, <-startedC)
+	proceedC <- struct{}{}
+	<-o.done
+
+	want := []time.Duration{time.Second, 2001 * time.Millisecond}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got (%+v) != Want (%+v)", got, want)
+	}
+}
+
+func TestTimingWheel_AfterFunc_FixedDelay(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), timingwheel.WithInlineTasks())
+
+	// FixedDelay has no effect on one-shot timers.
+	fired := 0
+	f := func() { fired++ }
+	timer := tw.AfterFunc(10*time.Millisecond, f, timingwheel.FixedDelay())
+	tw.AfterFuncKey(1, 10*time.Millisecond, f, timingwheel.FixedDelay())
+	g := tw.NewTimerGroup()
+	g.AfterFunc(10*time.Millisecond, f, timingwheel.FixedDelay())
+
+	clock.Advance(10 * time.Millisecond)
+	if fired != 3 {
+		t.Fatalf("Got (%+v) fired timers != Want (%+v)", fired, 3)
+	}
+	if timer.Stop() {
+		t.Fatalf("Stop: Got (%+v) != Want (%+v)", true, false)
+	}
+	if tw.Has(1) {
+		t.Fatalf("Has: Got (%+v) != Want (%+v)", true, false)
+	}
+	if n := g.Len(); n != 0 {
+		t.Fatalf("Len: Got (%+v) != Want (%+v)", n, 0)
+	}
+}
+
+func TestTimingWheel_ScheduleFunc_NoOverlap(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	o := &taskDoneObserver{done: make(chan struct{}, 1)}
+	clock, tw := newFakeWheel(t, start, timingwheel.WithObserver(o))
+
+	type run struct {
+		At     time.Duration
+		Missed int
+	}
+	runC := make(chan run)
+	proceedC := make(chan struct{})
+	s := &scheduler{intervals: []time.Duration{time.Second, time.Second, time.Second}}
+	tw.ScheduleFuncContext(context.Background(), s, func(ctx context.Context) {
+		runC <- run{At: clock.Now().Sub(start), Missed: timingwheel.MissedRuns(ctx)}
+		<-proceedC
+	}, timingwheel.NoOverlap())
+
+	var got []run
+
+	// The first execution takes 1.5s, thus the second one is skipped.
+	clock.Advance(time.Second)
+	got = append(got, <-runC)
+	clock.Advance(time.Second)
+	<-o.done
+	clock.Advance(500 * time.Millisecond)
+	proceedC <- struct{}{}
+	<-o.done
+
+	clock.Advance(500 * time.Millisecond)
+	got = append(got, <-runC)
+	proceedC <- struct{}{}
+	<-o.done
+
+	want := []run{
+		{At: time.Second},
+		{At: 3 * time.Second, Missed: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got (%+v) != Want (%+v)", got, want)
+	}
+}
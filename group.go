@@ -0,0 +1,93 @@
+package timingwheel
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TimerGroup is a group of timers, which can be stopped all at once, e.g.
+// when the tenant or the connection that they belong to goes away.
+//
+// A timer is removed from the group automatically once it expires (for a
+// recurring timer, once its execution plan ends), even if its task is not
+// run (e.g. rejected by the executor), or once it is stopped, or drained by
+// Shutdown or StopAndDrain.
+type TimerGroup struct {
+	tw *TimingWheel
+
+	mu     sync.Mutex
+	timers map[*Timer]struct{}
+}
+
+// NewTimerGroup creates an empty TimerGroup, whose timers will be added
+// into the timing wheel.
+func (tw *TimingWheel) NewTimerGroup() *TimerGroup {
+	return &TimerGroup{
+		tw:     tw,
+		timers: make(map[*Timer]struct{}),
+	}
+}
+
+// AfterFunc is like TimingWheel.AfterFunc, except that the timer is added
+// into the group.
+func (g *TimerGroup) AfterFunc(d time.Duration, f func(), opts ...TimerOption) *Timer {
+	return g.tw.AfterFunc(d, f, append(opts[:len(opts):len(opts)], g.member())...)
+}
+
+// ScheduleFunc is like TimingWheel.ScheduleFunc, except that the timer is
+// added into the group.
+func (g *TimerGroup) ScheduleFunc(s Scheduler, f func(), opts ...TimerOption) *Timer {
+	return g.tw.ScheduleFunc(s, f, append(opts[:len(opts):len(opts)], g.member())...)
+}
+
+// Len returns the number of timers in the group.
+func (g *TimerGroup) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.timers)
+}
+
+// StopAll stops all the timers in the group, and returns the number of
+// timers that are stopped by the call.
+func (g *TimerGroup) StopAll() (n int) {
+	g.mu.Lock()
+	timers := g.timers
+	g.timers = make(map[*Timer]struct{})
+	g.mu.Unlock()
+
+	for t := range timers {
+		if t.Stop() {
+			n++
+		}
+	}
+	return
+}
+
+// member returns a TimerOption, which adds the timer into the group.
+func (g *TimerGroup) member() TimerOption {
+	return func(t *Timer) {
+		if atomic.LoadInt32(&g.tw.closed) == 1 {
+			// The timer will be rejected.
+			return
+		}
+
+		t.release = func() {
+			g.remove(t)
+		}
+
+		g.mu.Lock()
+		g.timers[t] = struct{}{}
+		g.mu.Unlock()
+	}
+}
+
+// remove removes the timer t from the group, if t is no longer pending.
+func (g *TimerGroup) remove(t *Timer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !t.pending() {
+		delete(g.timers, t)
+	}
+}
@@ -0,0 +1,144 @@
+package timingwheel_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+)
+
+func TestTimerGroup(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), timingwheel.WithInlineTasks())
+
+	var fired int
+	f := func() { fired++ }
+
+	g := tw.NewTimerGroup()
+	g.AfterFunc(10*time.Millisecond, f)
+	g.AfterFunc(20*time.Millisecond, f)
+	g.AfterFunc(time.Second, f)
+	stopped := g.AfterFunc(time.Second, f)
+	g.ScheduleFunc(&scheduler{intervals: []time.Duration{
+		10 * time.Millisecond,
+		10 * time.Millisecond,
+	}}, f)
+	g.ScheduleFunc(&scheduler{intervals: []time.Duration{
+		10 * time.Millisecond,
+		time.Second,
+	}}, f)
+
+	// Timers not in the group are not affected.
+	other := tw.AfterFunc(time.Second, f)
+	defer other.Stop()
+
+	if n := g.Len(); n != 6 {
+		t.Fatalf("Len: Got (%+v) != Want (%+v)", n, 6)
+	}
+
+	stopped.Stop()
+	clock.Advance(20 * time.Millisecond)
+
+	// The first two timers and the first schedule have ended.
+	if fired != 5 {
+		t.Fatalf("Fired: Got (%+v) != Want (%+v)", fired, 5)
+	}
+	if n := g.Len(); n != 2 {
+		t.Fatalf("Len: Got (%+v) != Want (%+v)", n, 2)
+	}
+
+	if n := g.StopAll(); n != 2 {
+		t.Fatalf("StopAll: Got (%+v) != Want (%+v)", n, 2)
+	}
+	if n := g.Len(); n != 0 {
+		t.Fatalf("Len: Got (%+v) != Want (%+v)", n, 0)
+	}
+	if n := g.StopAll(); n != 0 {
+		t.Fatalf("StopAll: Got (%+v) != Want (%+v)", n, 0)
+	}
+
+	clock.Advance(2 * time.Second)
+	if fired != 6 {
+		t.Fatalf("Fired: Got (%+v) != Want (%+v)", fired, 6)
+	}
+}
+
+func TestTimerGroup_Release(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("misfire skip", func(t *testing.T) {
+		_, tw := newFakeWheel(t, start, timingwheel.WithInlineTasks())
+		g := tw.NewTimerGroup()
+
+		// The execution plan ends while the late executions are skipped.
+		s := &lateScheduler{next: start.Add(-2 * time.Second), d: time.Second, end: start.Add(-time.Second)}
+		g.ScheduleFunc(s, func() {
+			t.Error("Skipped task was run")
+		}, timingwheel.WithMisfirePolicy(timingwheel.MisfireSkip))
+
+		if n := g.Len(); n != 0 {
+			t.Fatalf("Len: Got (%+v) != Want (%+v)", n, 0)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		p := newSaturatedPool(t, timingwheel.Drop)
+		clock, tw := newFakeWheel(t, start, timingwheel.WithExecutor(p))
+		g := tw.NewTimerGroup()
+
+		g.AfterFunc(10*time.Millisecond, func() {
+			t.Error("Rejected task was run")
+		})
+		clock.Advance(10 * time.Millisecond)
+
+		if n := g.Len(); n != 0 {
+			t.Fatalf("Len: Got (%+v) != Want (%+v)", n, 0)
+		}
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		_, tw := newFakeWheel(t, start)
+		g := tw.NewTimerGroup()
+
+		g.AfterFunc(time.Hour, func() {})
+		if _, err := tw.Shutdown(context.Background(), timingwheel.DiscardPending); err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+
+		if n := g.Len(); n != 0 {
+			t.Fatalf("Len: Got (%+v) != Want (%+v)", n, 0)
+		}
+	})
+}
+
+func TestTimerGroup_SharedOptions(t *testing.T) {
+	_, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	// The options have spare capacity, which must not be written into.
+	opts := make([]timingwheel.TimerOption, 1, 2)
+	opts[0] = timingwheel.WithName("shared")
+
+	const n = 100
+	groups := []*timingwheel.TimerGroup{tw.NewTimerGroup(), tw.NewTimerGroup()}
+	var wg sync.WaitGroup
+	for _, g := range groups {
+		wg.Add(1)
+		go func(g *timingwheel.TimerGroup) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				g.AfterFunc(time.Second, func() {}, opts...)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for i, g := range groups {
+		if got := g.Len(); got != n {
+			t.Fatalf("Group %d: Len: Got (%+v) != Want (%+v)", i, got, n)
+		}
+	}
+	if opts[:2][1] != nil {
+		t.Fatalf("The spare capacity of the options has been written into")
+	}
+}
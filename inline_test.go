@@ -0,0 +1,108 @@
+package timingwheel_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+)
+
+func TestTimingWheel_AfterFunc_Inline(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	// The first timer stops the other two timers, one of which is in the
+	// same bucket and has expired at the same time.
+	fired := 0
+	var second, third *timingwheel.Timer
+	tw.AfterFunc(10*time.Millisecond, func() {
+		fired++
+		if second.Stop() {
+			t.Error("Stop of an expired timer returned true")
+		}
+		if !third.Stop() {
+			t.Error("Stop of an active timer returned false")
+		}
+	}, timingwheel.Inline())
+	second = tw.AfterFunc(10*time.Millisecond, func() {
+		fired++
+	}, timingwheel.Inline())
+	third = tw.AfterFunc(20*time.Millisecond, func() {
+		fired++
+	}, timingwheel.Inline())
+
+	clock.Advance(20 * time.Millisecond)
+
+	// Inline tasks are completed once Advance returns.
+	if fired != 2 {
+		t.Fatalf("Got (%+v) fired timers != Want (%+v)", fired, 2)
+	}
+}
+
+func TestTimingWheel_WithInlineGuard(t *testing.T) {
+	var slow *timingwheel.Timer
+	var elapsed time.Duration
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		timingwheel.WithInlineTasks(),
+		timingwheel.WithInlineGuard(5*time.Millisecond, func(t *timingwheel.Timer, d time.Duration) {
+			slow, elapsed = t, d
+		}),
+	)
+
+	tw.AfterFunc(10*time.Millisecond, func() {})
+	timer := tw.AfterFunc(20*time.Millisecond, func() {
+		time.Sleep(10 * time.Millisecond)
+	})
+
+	clock.Advance(10 * time.Millisecond)
+	if slow != nil {
+		t.Fatalf("Got unexpected slow timer %v", slow)
+	}
+
+	clock.Advance(10 * time.Millisecond)
+	if slow != timer || elapsed < 10*time.Millisecond {
+		t.Fatalf("Got slow timer (%v, %s), want (%v, >=10ms)", slow, elapsed, timer)
+	}
+}
+
+func TestTimer_Reset_ExpiredTaskStopsItself(t *testing.T) {
+	// The tasks will be run by the callers.
+	p := newSaturatedPool(t, timingwheel.CallerRuns)
+
+	cases := []struct {
+		name string
+		opts []timingwheel.Option
+	}{
+		{
+			name: "inline",
+		},
+		{
+			name: "caller runs",
+			opts: []timingwheel.Option{timingwheel.WithExecutor(p)},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), c.opts...)
+
+			var opts []timingwheel.TimerOption
+			if c.opts == nil {
+				opts = append(opts, timingwheel.Inline())
+			}
+			var timer *timingwheel.Timer
+			fired := 0
+			timer = tw.AfterFunc(time.Hour, func() {
+				fired++
+				// The task must not deadlock when stopping its own timer.
+				timer.Stop()
+			}, opts...)
+
+			// The timer expires immediately, and its task is run by Reset.
+			if !timer.Reset(0) {
+				t.Fatalf("Reset: Got (%+v) != Want (%+v)", false, true)
+			}
+			if fired != 1 {
+				t.Fatalf("Got (%+v) fired timers != Want (%+v)", fired, 1)
+			}
+		})
+	}
+}
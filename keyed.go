@@ -0,0 +1,135 @@
+package timingwheel
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// keyIndex is an index of the keyed timers.
+type keyIndex struct {
+	mu     sync.Mutex
+	timers map[interface{}]*Timer
+}
+
+// swap associates key with the timer t, and returns the timer previously
+// associated with key, if any.
+func (idx *keyIndex) swap(key interface{}, t *Timer) *Timer {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.timers == nil {
+		idx.timers = make(map[interface{}]*Timer)
+	}
+	old := idx.timers[key]
+	idx.timers[key] = t
+	return old
+}
+
+// get returns the timer associated with key.
+func (idx *keyIndex) get(key interface{}) (*Timer, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	t, ok := idx.timers[key]
+	return t, ok
+}
+
+// remove removes the association between key and the timer t, if t is
+// still associated with key and is no longer pending.
+func (idx *keyIndex) remove(key interface{}, t *Timer) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.timers[key] == t && t.getBucket() == nil {
+		delete(idx.timers, key)
+	}
+}
+
+// restore associates key with the timer t again, if no timer is associated
+// with key and t is pending.
+func (idx *keyIndex) restore(key interface{}, t *Timer) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, ok := idx.timers[key]; !ok && t.getBucket() != nil {
+		idx.timers[key] = t
+	}
+}
+
+// clear removes all the associations.
+func (idx *keyIndex) clear() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.timers = nil
+}
+
+// AfterFuncKey is like AfterFunc, except that the timer is associated with
+// key, which must be comparable, so that it can be stopped or reset by key.
+// If there is already a timer associated with key, it will be stopped and
+// replaced.
+//
+// The association is removed automatically once the timer expires or is
+// stopped, thus there is no need to keep track of the timer.
+func (tw *TimingWheel) AfterFuncKey(key interface{}, d time.Duration, f func(), opts ...TimerOption) *Timer {
+	t := &Timer{
+		id:         tw.nextTimerID(),
+		expiration: timeToNs(tw.clock.Now().UTC().Add(d)),
+		tw:         tw,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.task = f
+	t.release = func() {
+		tw.keys.remove(key, t)
+	}
+
+	if atomic.LoadInt32(&tw.closed) == 1 {
+		// The timer will be rejected, so do not associate it with key.
+		return t
+	}
+	// Submit the timer before associating it with key, so that a concurrent
+	// call with the same key, which replaces the timer, can always stop it.
+	tw.submit(t)
+	if old := tw.keys.swap(key, t); old != nil {
+		old.Stop()
+	}
+	// The timer may have expired, and thus been released, before being
+	// associated with key.
+	tw.keys.remove(key, t)
+	return t
+}
+
+// StopKey stops the timer associated with key. It returns true if the call
+// stops the timer, false if there is no such timer, or the timer has
+// already expired.
+func (tw *TimingWheel) StopKey(key interface{}) bool {
+	t, ok := tw.keys.get(key)
+	if !ok {
+		return false
+	}
+	return t.Stop()
+}
+
+// ResetKey changes the timer associated with key to expire after duration d.
+// It returns true if the timer has been reset, false if there is no timer
+// associated with key, i.e. the timer has expired or been stopped.
+func (tw *TimingWheel) ResetKey(key interface{}, d time.Duration) bool {
+	t, ok := tw.keys.get(key)
+	if !ok {
+		return false
+	}
+
+	t.Reset(d)
+	// The timer may have expired, and thus been dissociated from key,
+	// just before being reset.
+	tw.keys.restore(key, t)
+	return true
+}
+
+// Has reports whether there is a pending timer associated with key.
+func (tw *TimingWheel) Has(key interface{}) bool {
+	t, ok := tw.keys.get(key)
+	return ok && t.getBucket() != nil
+}
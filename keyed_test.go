@@ -0,0 +1,160 @@
+package timingwheel_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+)
+
+func TestTimingWheel_AfterFuncKey(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), timingwheel.WithInlineTasks())
+
+	var fired []string
+	fire := func(name string) func() {
+		return func() { fired = append(fired, name) }
+	}
+
+	t.Run("expire", func(t *testing.T) {
+		fired = nil
+		tw.AfterFuncKey("a", 10*time.Millisecond, fire("a"))
+		if !tw.Has("a") {
+			t.Fatalf("Has: Got (%+v) != Want (%+v)", false, true)
+		}
+
+		clock.Advance(10 * time.Millisecond)
+		if tw.Has("a") || len(fired) != 1 {
+			t.Fatalf("Got (%+v, %+v) != Want (%+v, %+v)", tw.Has("a"), fired, false, []string{"a"})
+		}
+		if tw.StopKey("a") || tw.ResetKey("a", time.Millisecond) {
+			t.Fatalf("Expired key can still be stopped or reset")
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		fired = nil
+		tw.AfterFuncKey("b", 10*time.Millisecond, fire("b"))
+		if !tw.StopKey("b") {
+			t.Fatalf("StopKey: Got (%+v) != Want (%+v)", false, true)
+		}
+		if tw.Has("b") || tw.StopKey("b") {
+			t.Fatalf("Stopped key still exists")
+		}
+
+		// Stopping the timer directly also removes the key.
+		timer := tw.AfterFuncKey("b", 10*time.Millisecond, fire("b"))
+		timer.Stop()
+		if tw.Has("b") {
+			t.Fatalf("Has: Got (%+v) != Want (%+v)", true, false)
+		}
+
+		clock.Advance(10 * time.Millisecond)
+		if len(fired) != 0 {
+			t.Fatalf("Got (%+v) != Want no fired timers", fired)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		fired = nil
+		tw.AfterFuncKey("c", 10*time.Millisecond, fire("c"))
+		clock.Advance(5 * time.Millisecond)
+		if !tw.ResetKey("c", 10*time.Millisecond) {
+			t.Fatalf("ResetKey: Got (%+v) != Want (%+v)", false, true)
+		}
+
+		clock.Advance(5 * time.Millisecond)
+		if !tw.Has("c") || len(fired) != 0 {
+			t.Fatalf("Got (%+v, %+v) != Want (%+v, no fired timers)", tw.Has("c"), fired, true)
+		}
+		clock.Advance(5 * time.Millisecond)
+		if tw.Has("c") || len(fired) != 1 {
+			t.Fatalf("Got (%+v, %+v) != Want (%+v, %+v)", tw.Has("c"), fired, false, []string{"c"})
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		fired = nil
+		tw.AfterFuncKey("d", 10*time.Millisecond, fire("d1"))
+		tw.AfterFuncKey("d", 20*time.Millisecond, fire("d2"))
+
+		clock.Advance(20 * time.Millisecond)
+		if len(fired) != 1 || fired[0] != "d2" {
+			t.Fatalf("Got (%+v) != Want (%+v)", fired, []string{"d2"})
+		}
+		if tw.Has("d") {
+			t.Fatalf("Has: Got (%+v) != Want (%+v)", true, false)
+		}
+	})
+
+	t.Run("expired on add", func(t *testing.T) {
+		fired = nil
+		tw.AfterFuncKey("e", 0, fire("e"))
+		if tw.Has("e") || len(fired) != 1 {
+			t.Fatalf("Got (%+v, %+v) != Want (%+v, %+v)", tw.Has("e"), fired, false, []string{"e"})
+		}
+	})
+}
+
+func TestTimingWheel_AfterFuncKey_StopAndDrain(t *testing.T) {
+	_, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	tw.AfterFuncKey(1, time.Second, func() {})
+	if timers := tw.StopAndDrain(); len(timers) != 1 {
+		t.Fatalf("Got (%+v) pending timers != Want (%+v)", len(timers), 1)
+	}
+	if tw.Has(1) || tw.ResetKey(1, time.Second) {
+		t.Fatalf("Drained key still exists")
+	}
+}
+
+func TestTimingWheel_AfterFuncKey_Rejected(t *testing.T) {
+	p := newSaturatedPool(t, timingwheel.Drop)
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), timingwheel.WithExecutor(p))
+
+	tw.AfterFuncKey(1, 10*time.Millisecond, func() {
+		t.Error("Rejected task was run")
+	})
+
+	// The task is rejected, but the key is still dissociated.
+	clock.Advance(10 * time.Millisecond)
+	if got := p.Stats().Dropped; got != 1 {
+		t.Fatalf("Dropped: Got (%+v) != Want (%+v)", got, 1)
+	}
+	if tw.ResetKey(1, time.Second) {
+		t.Fatalf("Key of the rejected timer still exists")
+	}
+}
+
+func TestTimingWheel_AfterFuncKey_Concurrent(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), timingwheel.WithInlineTasks())
+
+	const rounds, n = 100, 32
+	for r := 0; r < rounds; r++ {
+		var fired int32
+		var wg sync.WaitGroup
+		startC := make(chan struct{})
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-startC
+				tw.AfterFuncKey(1, 10*time.Millisecond, func() {
+					atomic.AddInt32(&fired, 1)
+				})
+			}()
+		}
+		close(startC)
+		wg.Wait()
+
+		// Only the timer that replaced all the others fires.
+		clock.Advance(10 * time.Millisecond)
+		if got := atomic.LoadInt32(&fired); got != 1 {
+			t.Fatalf("Round %d: Got (%+v) fired timers != Want (%+v)", r, got, 1)
+		}
+		if tw.Has(1) {
+			t.Fatalf("Round %d: Has: Got (%+v) != Want (%+v)", r, true, false)
+		}
+	}
+}
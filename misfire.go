@@ -0,0 +1,80 @@
+package timingwheel
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// MisfirePolicy determines how a recurring timer (e.g. the one created by
+// ScheduleFunc) handles the executions it has missed, when the timing wheel
+// falls behind (e.g. due to GC pauses or a suspended VM), or when the
+// execution plan starts in the past.
+//
+// When the timer expires, it asks its scheduler for the next execution time.
+// If that time has already passed, the current execution is late by at least
+// one whole interval of the execution plan, and the passed times are missed.
+type MisfirePolicy int
+
+const (
+	// MisfireFireAll executes all the missed executions back to back, as
+	// soon as possible. This is the default policy.
+	MisfireFireAll MisfirePolicy = iota
+
+	// MisfireFireOnce executes the late execution once, skips all the missed
+	// ones, and continues with the first execution time in the future.
+	MisfireFireOnce
+
+	// MisfireSkip skips the late execution along with all the missed ones,
+	// and continues with the first execution time in the future.
+	MisfireSkip
+)
+
+// WithMisfirePolicy sets the misfire policy of a recurring timer, which
+// defaults to MisfireFireAll. It has no effect on one-shot timers.
+//
+// The number of the executions skipped by the policy can be learned by the
+// task of ScheduleFuncContext through MissedRuns.
+func WithMisfirePolicy(p MisfirePolicy) TimerOption {
+	return func(t *Timer) {
+		t.misfire = p
+	}
+}
+
+// MissedRuns returns the number of executions that have been skipped, due to
+// the misfire policy or NoOverlap, since the previous execution of the task.
+// It must be called with the context passed to the task of
+// ScheduleFuncContext, and it returns 0 for any other context.
+func MissedRuns(ctx context.Context) int {
+	e, _ := ExecutionFromContext(ctx)
+	return e.Missed
+}
+
+// skipMissed applies the misfire policy of the recurring timer t, given
+// its next expiration. If next is already due, skipMissed skips the due
+// expirations, and returns the first one in the future, or false if the
+// execution plan ends. It also reports whether the current (late) execution
+// of t should be skipped.
+func (tw *TimingWheel) skipMissed(t *Timer, next int64) (_ int64, ok, skip bool) {
+	now := timeToNs(tw.clock.Now())
+	if t.misfire == MisfireFireAll || next > now {
+		return next, true, false
+	}
+
+	skip = t.misfire == MisfireSkip
+	if skip {
+		atomic.AddInt32(&t.missed, 1)
+	}
+	for next <= now {
+		atomic.AddInt32(&t.missed, 1)
+		if next, ok = t.reschedule(next); !ok {
+			return 0, false, skip
+		}
+	}
+	return next, true, skip
+}
+
+// takeMissed returns the number of the executions of t skipped since its
+// previous execution, and resets the number.
+func (t *Timer) takeMissed() int {
+	return int(atomic.SwapInt32(&t.missed, 0))
+}
@@ -0,0 +1,111 @@
+package timingwheel_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+)
+
+// lateScheduler is a scheduler whose times are spaced d apart, starting from
+// a time in the past, as if the timing wheel had fallen behind.
+type lateScheduler struct {
+	next time.Time
+	d    time.Duration
+	end  time.Time // if not zero, the execution plan ends after end
+}
+
+func (s *lateScheduler) Next(prev time.Time) time.Time {
+	if !s.end.IsZero() && s.next.After(s.end) {
+		return time.Time{}
+	}
+	next := s.next
+	s.next = s.next.Add(s.d)
+	return next
+}
+
+type misfireRun struct {
+	At     time.Duration
+	Missed int
+}
+
+func TestTimingWheel_ScheduleFunc_MisfirePolicy(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// The times are -3.5s, -2.5s, -1.5s, -0.5s, 0.5s, 1.5s, ...
+	cases := []struct {
+		name   string
+		policy timingwheel.MisfirePolicy
+		want   []misfireRun
+	}{
+		{
+			name:   "fire all",
+			policy: timingwheel.MisfireFireAll,
+			want: []misfireRun{
+				{At: 0}, {At: 0}, {At: 0}, {At: 0},
+				{At: 500 * time.Millisecond},
+				{At: 1500 * time.Millisecond},
+			},
+		},
+		{
+			name:   "fire once",
+			policy: timingwheel.MisfireFireOnce,
+			want: []misfireRun{
+				{At: 0, Missed: 3},
+				{At: 500 * time.Millisecond},
+				{At: 1500 * time.Millisecond},
+			},
+		},
+		{
+			name:   "skip",
+			policy: timingwheel.MisfireSkip,
+			want: []misfireRun{
+				{At: 500 * time.Millisecond, Missed: 4},
+				{At: 1500 * time.Millisecond},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clock, tw := newFakeWheel(t, start, timingwheel.WithInlineTasks())
+
+			var got []misfireRun
+			s := &lateScheduler{next: start.Add(-3500 * time.Millisecond), d: time.Second}
+			tw.ScheduleFuncContext(context.Background(), s, func(ctx context.Context) {
+				got = append(got, misfireRun{
+					At:     clock.Now().Sub(start),
+					Missed: timingwheel.MissedRuns(ctx),
+				})
+			}, timingwheel.WithMisfirePolicy(c.policy))
+
+			clock.Advance(2 * time.Second)
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("Got (%+v) != Want (%+v)", got, c.want)
+			}
+		})
+	}
+}
+
+func TestTimingWheel_ScheduleFunc_MisfirePolicy_End(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, tw := newFakeWheel(t, start, timingwheel.WithInlineTasks())
+
+	// The execution plan ends while the missed executions are skipped.
+	s := &lateScheduler{next: start.Add(-2 * time.Second), d: time.Second, end: start.Add(-time.Second)}
+
+	fired := 0
+	timer := tw.ScheduleFunc(s, func() {
+		fired++
+	}, timingwheel.WithMisfirePolicy(timingwheel.MisfireFireOnce))
+
+	if fired != 1 {
+		t.Fatalf("Got (%+v) != Want (%+v)", fired, 1)
+	}
+	if timer.Stop() {
+		t.Fatalf("Stop: Got (%+v) != Want (%+v)", true, false)
+	}
+}
@@ -0,0 +1,73 @@
+package timingwheel
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Observer observes the lifecycle events of the timers in a timing wheel,
+// which is useful for logging, tracing or collecting metrics.
+//
+// All the methods are called synchronously, and some of them are called
+// in the timing wheel's goroutine, so they must be fast and must never block.
+// The time passed to each method is the current time of the timing wheel's
+// clock when the event happened.
+//
+// No method is called while the timing wheel holds a lock of any timer or
+// bucket, thus the observer is free to stop or reset timers, including the
+// one being observed.
+type Observer interface {
+	// OnAdd is called when the timer t is added into the timing wheel,
+	// which happens when it is created or reset, or when a recurring timer
+	// is rescheduled for its next execution.
+	OnAdd(t *Timer, now time.Time)
+
+	// OnStop is called when the timer t is stopped before it expires,
+	// either explicitly or by its context.
+	OnStop(t *Timer, now time.Time)
+
+	// OnCascade is called when the timer t is moved from a higher-level
+	// overflow wheel down into a lower-level wheel.
+	OnCascade(t *Timer, now time.Time)
+
+	// OnExpire is called when the timer t expires, before its task is
+	// executed.
+	OnExpire(t *Timer, now time.Time)
+
+	// OnTaskDone is called when the task of the timer t, which was started
+	// at start, returns (or panics) at end.
+	OnTaskDone(t *Timer, start, end time.Time)
+}
+
+// WithObserver sets an observer of the timers' lifecycle events.
+func WithObserver(o Observer) Option {
+	return func(tw *TimingWheel) {
+		tw.observer = o
+	}
+}
+
+// notify appends the event of the timer t, which happens while a lock is
+// held, to events, if there is an observer to be notified of it later.
+func (tw *TimingWheel) notify(events []timerEvent, kind eventKind, t *Timer) []timerEvent {
+	if kind == eventExpire {
+		atomic.AddUint64(&tw.stats.fired, 1)
+	}
+	if tw.observer == nil {
+		return events
+	}
+	return append(events, timerEvent{kind: kind, t: t, now: tw.clock.Now()})
+}
+
+func (tw *TimingWheel) onStop(t *Timer) {
+	atomic.AddUint64(&tw.stats.stopped, 1)
+	if tw.observer != nil {
+		tw.observer.OnStop(t, tw.clock.Now())
+	}
+}
+
+func (tw *TimingWheel) onExpire(t *Timer) {
+	atomic.AddUint64(&tw.stats.fired, 1)
+	if tw.observer != nil {
+		tw.observer.OnExpire(t, tw.clock.Now())
+	}
+}
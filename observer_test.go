@@ -0,0 +1,134 @@
+package timingwheel_test
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+)
+
+type observedEvent struct {
+	name  string
+	timer *timingwheel.Timer
+	at    time.Time
+}
+
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []observedEvent
+}
+
+func (o *recordingObserver) record(name string, t *timingwheel.Timer, at time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, observedEvent{name: name, timer: t, at: at})
+}
+
+func (o *recordingObserver) OnAdd(t *timingwheel.Timer, now time.Time)  { o.record("add", t, now) }
+func (o *recordingObserver) OnStop(t *timingwheel.Timer, now time.Time) { o.record("stop", t, now) }
+func (o *recordingObserver) OnCascade(t *timingwheel.Timer, now time.Time) {
+	o.record("cascade", t, now)
+}
+func (o *recordingObserver) OnExpire(t *timingwheel.Timer, now time.Time) { o.record("expire", t, now) }
+func (o *recordingObserver) OnTaskDone(t *timingwheel.Timer, start, end time.Time) {
+	o.record("done", t, end)
+}
+
+// Events returns the recorded events, formatted with the names of the timers
+// and the elapsed time since start.
+func (o *recordingObserver) Events(names map[*timingwheel.Timer]string, start time.Time) (events []string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, e := range o.events {
+		events = append(events, fmt.Sprintf("%s %s@%v", e.name, names[e.timer], e.at.Sub(start)))
+	}
+	return
+}
+
+func TestTimingWheel_WithObserver(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	o := &recordingObserver{}
+	clock, tw := newFakeWheel(t, start,
+		timingwheel.WithInlineTasks(),
+		timingwheel.WithObserver(o),
+	)
+
+	a := tw.AfterFunc(5*time.Millisecond, func() {})
+	b := tw.AfterFunc(25*time.Millisecond, func() {})
+	c := tw.AfterFunc(50*time.Millisecond, func() {})
+	d := tw.ScheduleFunc(&scheduler{intervals: []time.Duration{
+		10 * time.Millisecond,
+		10 * time.Millisecond,
+	}}, func() {})
+
+	clock.Advance(15 * time.Millisecond)
+	c.Stop()
+	clock.Advance(15 * time.Millisecond)
+
+	names := map[*timingwheel.Timer]string{a: "a", b: "b", c: "c", d: "d"}
+	got := o.Events(names, start)
+	want := []string{
+		"add a@0s",
+		"add b@0s",
+		"add c@0s",
+		"add d@0s",
+		"expire a@5ms",
+		"done a@5ms",
+		"expire d@10ms",
+		"add d@10ms",
+		"done d@10ms",
+		"stop c@15ms",
+		// b has been put into the overflow wheel, whose bucket expires at 20ms.
+		"cascade b@20ms",
+		"expire d@20ms",
+		"done d@20ms",
+		"expire b@25ms",
+		"done b@25ms",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got (%+v) != Want (%+v)", got, want)
+	}
+}
+
+// expireObserver calls f whenever a timer expires.
+type expireObserver struct {
+	f func(t *timingwheel.Timer)
+}
+
+func (o *expireObserver) OnAdd(t *timingwheel.Timer, now time.Time)             {}
+func (o *expireObserver) OnStop(t *timingwheel.Timer, now time.Time)            {}
+func (o *expireObserver) OnCascade(t *timingwheel.Timer, now time.Time)         {}
+func (o *expireObserver) OnExpire(t *timingwheel.Timer, now time.Time)          { o.f(t) }
+func (o *expireObserver) OnTaskDone(t *timingwheel.Timer, start, end time.Time) {}
+
+func TestTimingWheel_WithObserver_StopTimer(t *testing.T) {
+	var a, b *timingwheel.Timer
+	stopped := false
+	o := &expireObserver{f: func(t *timingwheel.Timer) {
+		if t == a {
+			// b is in the same bucket as a.
+			stopped = b.Stop()
+		}
+	}}
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		timingwheel.WithInlineTasks(),
+		timingwheel.WithObserver(o),
+	)
+
+	fired := 0
+	a = tw.AfterFunc(10*time.Millisecond, func() {})
+	s := &scheduler{intervals: []time.Duration{10 * time.Millisecond, 10 * time.Millisecond}}
+	b = tw.ScheduleFunc(s, func() { fired++ })
+
+	clock.Advance(20 * time.Millisecond)
+	if !stopped {
+		t.Fatalf("Stop: Got (%+v) != Want (%+v)", false, true)
+	}
+	// The execution at 10ms had been collected when b was stopped.
+	if fired != 1 {
+		t.Fatalf("Got (%+v) fired timers != Want (%+v)", fired, 1)
+	}
+}
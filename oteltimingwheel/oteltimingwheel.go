@@ -0,0 +1,120 @@
+// Package oteltimingwheel provides OpenTelemetry tracing for the tasks
+// scheduled in a timing wheel.
+//
+// Since a task usually runs long after it was scheduled, the span of its
+// execution is not a child of the span in which it was scheduled. Instead,
+// the execution span starts a new trace, which is linked to the scheduling
+// span, thus the task's work will not show up as an orphan trace.
+package oteltimingwheel
+
+import (
+	"context"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name.
+const ScopeName = "github.com/RussellLuo/timingwheel/oteltimingwheel"
+
+// The attributes recorded on the execution spans.
+const (
+	// ScheduledTimeKey is the time at which the task was scheduled to run.
+	ScheduledTimeKey = attribute.Key("timingwheel.scheduled_time")
+	// LatenessKey is the time (in nanoseconds) between the scheduled time
+	// and the start of the task, both of which are measured by the clock
+	// of the timing wheel.
+	LatenessKey = attribute.Key("timingwheel.lateness_ns")
+	// RescheduledKey tells whether the timer has been rescheduled for
+	// another execution. It is always false for fixed-delay timers, which
+	// are rescheduled only after their tasks return.
+	RescheduledKey = attribute.Key("timingwheel.rescheduled")
+)
+
+// Option is a function that configures a Wheel.
+type Option func(*Wheel)
+
+// WithTracerProvider sets the tracer provider, which defaults to the
+// global one.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(w *Wheel) {
+		w.tracer = tp.Tracer(ScopeName)
+	}
+}
+
+// Wheel wraps a timing wheel, and traces the execution of the tasks
+// scheduled through it.
+type Wheel struct {
+	tw     *timingwheel.TimingWheel
+	tracer trace.Tracer
+}
+
+// New creates an instance of Wheel, which schedules the tasks in tw.
+func New(tw *timingwheel.TimingWheel, opts ...Option) *Wheel {
+	w := &Wheel{tw: tw}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.tracer == nil {
+		w.tracer = otel.GetTracerProvider().Tracer(ScopeName)
+	}
+	return w
+}
+
+// AfterFunc is like TimingWheel.AfterFunc, except that the span context of
+// ctx is captured, and f is called with a context holding a new span, which
+// is linked to the captured span context.
+//
+// The context passed to f carries the values of ctx, but it is never
+// canceled, since f usually runs after ctx is done.
+func (w *Wheel) AfterFunc(ctx context.Context, d time.Duration, f func(context.Context), opts ...timingwheel.TimerOption) *timingwheel.Timer {
+	link := trace.LinkFromContext(ctx)
+	ctx = context.WithoutCancel(ctx)
+
+	return w.tw.AfterFuncContext(context.Background(), d, func(taskCtx context.Context) {
+		w.run(ctx, taskCtx, "timingwheel.AfterFunc", link, f)
+	}, opts...)
+}
+
+// ScheduleFunc is like TimingWheel.ScheduleFunc, except that the span
+// context of ctx is captured, and f is called with a context holding a new
+// span, which is linked to the captured span context, for each execution.
+//
+// The context passed to f carries the values of ctx, but it is never
+// canceled, since f usually runs after ctx is done.
+func (w *Wheel) ScheduleFunc(ctx context.Context, s timingwheel.Scheduler, f func(context.Context), opts ...timingwheel.TimerOption) *timingwheel.Timer {
+	link := trace.LinkFromContext(ctx)
+	ctx = context.WithoutCancel(ctx)
+
+	return w.tw.ScheduleFuncContext(context.Background(), s, func(taskCtx context.Context) {
+		w.run(ctx, taskCtx, "timingwheel.ScheduleFunc", link, f)
+	}, opts...)
+}
+
+// run calls f with a new span, which is linked to link, and describes the
+// execution carried by taskCtx.
+func (w *Wheel) run(ctx, taskCtx context.Context, name string, link trace.Link, f func(context.Context)) {
+	e, _ := timingwheel.ExecutionFromContext(taskCtx)
+	attrs := []attribute.KeyValue{
+		RescheduledKey.Bool(e.Rescheduled),
+		ScheduledTimeKey.String(e.Scheduled.Format(time.RFC3339Nano)),
+		LatenessKey.Int64(int64(e.Started.Sub(e.Scheduled))),
+	}
+
+	opts := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	}
+	if link.SpanContext.IsValid() {
+		opts = append(opts, trace.WithLinks(link))
+	}
+
+	ctx, span := w.tracer.Start(ctx, name, opts...)
+	defer span.End()
+
+	f(ctx)
+}
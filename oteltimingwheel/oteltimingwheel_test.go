@@ -0,0 +1,273 @@
+package oteltimingwheel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+	"github.com/RussellLuo/timingwheel/oteltimingwheel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type scheduler struct {
+	intervals []time.Duration
+	current   int
+}
+
+func (s *scheduler) Next(prev time.Time) time.Time {
+	if s.current >= len(s.intervals) {
+		return time.Time{}
+	}
+	next := prev.Add(s.intervals[s.current])
+	s.current++
+	return next
+}
+
+// taskDoneObserver notifies when a task returns.
+type taskDoneObserver struct {
+	done chan struct{}
+}
+
+func (o *taskDoneObserver) OnAdd(t *timingwheel.Timer, now time.Time)     {}
+func (o *taskDoneObserver) OnStop(t *timingwheel.Timer, now time.Time)    {}
+func (o *taskDoneObserver) OnCascade(t *timingwheel.Timer, now time.Time) {}
+func (o *taskDoneObserver) OnExpire(t *timingwheel.Timer, now time.Time)  {}
+func (o *taskDoneObserver) OnTaskDone(t *timingwheel.Timer, start, end time.Time) {
+	o.done <- struct{}{}
+}
+
+// setup creates a Wheel, whose timing wheel runs the tasks inline, unless
+// other options are given.
+func setup(t *testing.T, opts ...timingwheel.Option) (*timingwheel.FakeClock, *oteltimingwheel.Wheel, *tracetest.InMemoryExporter, trace.Tracer) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	if len(opts) == 0 {
+		opts = []timingwheel.Option{timingwheel.WithInlineTasks()}
+	}
+	clock := timingwheel.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tw := timingwheel.NewTimingWheel(time.Millisecond, 20,
+		append([]timingwheel.Option{timingwheel.WithClock(clock)}, opts...)...,
+	)
+	tw.Start()
+	t.Cleanup(tw.Stop)
+
+	return clock, oteltimingwheel.New(tw, oteltimingwheel.WithTracerProvider(tp)), exporter, tp.Tracer("test")
+}
+
+func attrs(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestWheel_AfterFunc(t *testing.T) {
+	clock, w, exporter, tracer := setup(t)
+
+	ctx, parent := tracer.Start(context.Background(), "request")
+	ctx, cancel := context.WithCancel(ctx)
+	var taskCtx context.Context
+	w.AfterFunc(ctx, 10*time.Millisecond, func(ctx context.Context) {
+		taskCtx = ctx
+	})
+	parent.End()
+	cancel()
+
+	clock.Advance(10 * time.Millisecond)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("len(spans): Got (%+v) != Want (%+v)", len(spans), 2)
+	}
+	span := spans[1]
+
+	if span.Name != "timingwheel.AfterFunc" {
+		t.Fatalf("Name: Got (%+v) != Want (%+v)", span.Name, "timingwheel.AfterFunc")
+	}
+	if span.Parent.IsValid() || span.SpanContext.TraceID() == parent.SpanContext().TraceID() {
+		t.Fatalf("Span is not a new root: %+v", span)
+	}
+	if len(span.Links) != 1 || !span.Links[0].SpanContext.Equal(parent.SpanContext()) {
+		t.Fatalf("Links: Got (%+v) != Want a link to (%+v)", span.Links, parent.SpanContext())
+	}
+
+	a := attrs(span)
+	want := "2020-01-01T00:00:00.01Z"
+	if got := a[oteltimingwheel.ScheduledTimeKey].AsString(); got != want {
+		t.Fatalf("Scheduled time: Got (%+v) != Want (%+v)", got, want)
+	}
+	// The fake clock stands still while the task is started.
+	if v, ok := a[oteltimingwheel.LatenessKey]; !ok || v.AsInt64() != 0 {
+		t.Fatalf("Lateness: Got (%+v, %+v) != Want (%+v, %+v)", v.AsInt64(), ok, 0, true)
+	}
+	if a[oteltimingwheel.RescheduledKey].AsBool() {
+		t.Fatalf("Rescheduled: Got (%+v) != Want (%+v)", true, false)
+	}
+
+	if err := taskCtx.Err(); err != nil {
+		t.Fatalf("Task context: Got (%+v) != Want (%+v)", err, nil)
+	}
+	if got := trace.SpanContextFromContext(taskCtx); !got.Equal(span.SpanContext) {
+		t.Fatalf("Task span: Got (%+v) != Want (%+v)", got, span.SpanContext)
+	}
+}
+
+func TestWheel_ScheduleFunc(t *testing.T) {
+	clock, w, exporter, _ := setup(t)
+
+	// A context without any span, thus no link will be added.
+	w.ScheduleFunc(context.Background(), &scheduler{intervals: []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+	}}, func(ctx context.Context) {})
+
+	clock.Advance(30 * time.Millisecond)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("len(spans): Got (%+v) != Want (%+v)", len(spans), 2)
+	}
+
+	cases := []struct {
+		scheduled   string
+		rescheduled bool
+	}{
+		{"2020-01-01T00:00:00.01Z", true},
+		{"2020-01-01T00:00:00.03Z", false},
+	}
+	for i, c := range cases {
+		span := spans[i]
+		if span.Name != "timingwheel.ScheduleFunc" || len(span.Links) != 0 {
+			t.Fatalf("Span %d: Got (%+v, %+v) != Want (%+v, no links)", i, span.Name, span.Links, "timingwheel.ScheduleFunc")
+		}
+
+		a := attrs(span)
+		if got := a[oteltimingwheel.ScheduledTimeKey].AsString(); got != c.scheduled {
+			t.Fatalf("Span %d scheduled time: Got (%+v) != Want (%+v)", i, got, c.scheduled)
+		}
+		if got := a[oteltimingwheel.RescheduledKey].AsBool(); got != c.rescheduled {
+			t.Fatalf("Span %d rescheduled: Got (%+v) != Want (%+v)", i, got, c.rescheduled)
+		}
+		if got := a[oteltimingwheel.LatenessKey].AsInt64(); got != 0 {
+			t.Fatalf("Span %d lateness: Got (%+v) != Want (%+v)", i, got, 0)
+		}
+	}
+}
+
+func TestWheel_ScheduleFunc_MisfireSkip(t *testing.T) {
+	clock, w, exporter, _ := setup(t)
+
+	// The times are -2.5s, -1.5s, -0.5s, 0.5s and 1.5s, and the missed
+	// ones are skipped without any span.
+	w.ScheduleFunc(context.Background(), &scheduler{intervals: []time.Duration{
+		-2500 * time.Millisecond,
+		time.Second,
+		time.Second,
+		time.Second,
+		time.Second,
+	}}, func(ctx context.Context) {}, timingwheel.WithMisfirePolicy(timingwheel.MisfireSkip))
+
+	clock.Advance(2 * time.Second)
+
+	checkSpans(t, exporter.GetSpans(),
+		[]string{"2020-01-01T00:00:00.5Z", "2020-01-01T00:00:01.5Z"},
+		[]bool{true, false},
+	)
+}
+
+// checkSpans checks the scheduled times and the rescheduled flags of the
+// execution spans.
+func checkSpans(t *testing.T, spans tracetest.SpanStubs, scheduled []string, rescheduled []bool) {
+	t.Helper()
+	if len(spans) != len(scheduled) {
+		t.Fatalf("len(spans): Got (%+v) != Want (%+v)", len(spans), len(scheduled))
+	}
+	for i, span := range spans {
+		a := attrs(span)
+		if got := a[oteltimingwheel.ScheduledTimeKey].AsString(); got != scheduled[i] {
+			t.Fatalf("Span %d scheduled time: Got (%+v) != Want (%+v)", i, got, scheduled[i])
+		}
+		if got := a[oteltimingwheel.RescheduledKey].AsBool(); got != rescheduled[i] {
+			t.Fatalf("Span %d rescheduled: Got (%+v) != Want (%+v)", i, got, rescheduled[i])
+		}
+		if got := a[oteltimingwheel.LatenessKey].AsInt64(); got != 0 {
+			t.Fatalf("Span %d lateness: Got (%+v) != Want (%+v)", i, got, 0)
+		}
+	}
+}
+
+func TestWheel_ScheduleFunc_FixedDelay(t *testing.T) {
+	o := &taskDoneObserver{done: make(chan struct{}, 1)}
+	clock, w, exporter, _ := setup(t, timingwheel.WithObserver(o))
+
+	startedC := make(chan struct{})
+	proceedC := make(chan struct{})
+	w.ScheduleFunc(context.Background(), &scheduler{intervals: []time.Duration{
+		time.Second,
+		time.Second,
+	}}, func(ctx context.Context) {
+		startedC <- struct{}{}
+		<-proceedC
+	}, timingwheel.FixedDelay())
+
+	// The first execution takes 2.5s, and the next one is 1s after it
+	// returns.
+	clock.Advance(time.Second)
+	<-startedC
+	clock.Advance(2500 * time.Millisecond)
+	proceedC <- struct{}{}
+	<-o.done
+
+	clock.Advance(time.Second)
+	<-startedC
+	proceedC <- struct{}{}
+	<-o.done
+
+	checkSpans(t, exporter.GetSpans(),
+		[]string{"2020-01-01T00:00:01Z", "2020-01-01T00:00:04.5Z"},
+		[]bool{false, false},
+	)
+}
+
+func TestWheel_ScheduleFunc_NoOverlap(t *testing.T) {
+	o := &taskDoneObserver{done: make(chan struct{}, 1)}
+	clock, w, exporter, _ := setup(t, timingwheel.WithObserver(o))
+
+	startedC := make(chan struct{})
+	proceedC := make(chan struct{})
+	w.ScheduleFunc(context.Background(), &scheduler{intervals: []time.Duration{
+		time.Second,
+		time.Second,
+		time.Second,
+	}}, func(ctx context.Context) {
+		startedC <- struct{}{}
+		<-proceedC
+	}, timingwheel.NoOverlap())
+
+	// The first execution takes 1.5s, thus the second one is skipped
+	// without any span.
+	clock.Advance(time.Second)
+	<-startedC
+	clock.Advance(time.Second)
+	<-o.done
+	clock.Advance(500 * time.Millisecond)
+	proceedC <- struct{}{}
+	<-o.done
+
+	clock.Advance(500 * time.Millisecond)
+	<-startedC
+	proceedC <- struct{}{}
+	<-o.done
+
+	checkSpans(t, exporter.GetSpans(),
+		[]string{"2020-01-01T00:00:01Z", "2020-01-01T00:00:03Z"},
+		[]bool{true, false},
+	)
+}
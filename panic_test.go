@@ -0,0 +1,74 @@
+package timingwheel_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+)
+
+func TestTimingWheel_WithPanicHandler(t *testing.T) {
+	panicC := make(chan timingwheel.Panic, 10)
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		timingwheel.WithPanicHandler(func(p timingwheel.Panic) {
+			panicC <- p
+		}),
+	)
+
+	t.Run("after func", func(t *testing.T) {
+		timer := tw.AfterFunc(10*time.Millisecond, func() {
+			panic("boom")
+		})
+
+		clock.Advance(10 * time.Millisecond)
+
+		p := <-panicC
+		if p.Timer != timer || p.Value != "boom" {
+			t.Fatalf("Got panic (%v, %v), want (%v, %v)", p.Timer, p.Value, timer, "boom")
+		}
+		if !strings.Contains(string(p.Stack), "panic_test.go") {
+			t.Fatalf("Stack does not contain the panicking function:\n%s", p.Stack)
+		}
+	})
+
+	t.Run("schedule continues", func(t *testing.T) {
+		s := &scheduler{intervals: []time.Duration{
+			10 * time.Millisecond,
+			10 * time.Millisecond,
+			10 * time.Millisecond,
+		}}
+		tw.ScheduleFunc(s, func() {
+			panic("boom")
+		})
+
+		clock.Advance(30 * time.Millisecond)
+		for i := 0; i < len(s.intervals); i++ {
+			<-panicC
+		}
+	})
+
+	t.Run("schedule cancels", func(t *testing.T) {
+		s := &scheduler{intervals: []time.Duration{
+			10 * time.Millisecond,
+			10 * time.Millisecond,
+			10 * time.Millisecond,
+		}}
+		timer := tw.ScheduleFunc(s, func() {
+			panic("boom")
+		}, timingwheel.CancelOnPanic())
+
+		clock.Advance(10 * time.Millisecond)
+		<-panicC
+		if timer.Stop() {
+			t.Fatal("The execution plan was not terminated after panic")
+		}
+
+		clock.Advance(20 * time.Millisecond)
+		select {
+		case p := <-panicC:
+			t.Fatalf("Got unexpected panic %v", p.Value)
+		case <-time.After(10 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,130 @@
+// Package promcollector provides a Prometheus collector, which exports
+// the statistics of a timing wheel as Prometheus metrics.
+package promcollector
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefLatenessBuckets are the default upper bounds (in seconds) of the
+// buckets of the lateness histogram.
+var DefLatenessBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5, 10}
+
+// Opts are the options of a Collector.
+type Opts struct {
+	// Namespace and Subsystem are the prefix of the fully-qualified names
+	// of the metrics, e.g. "<Namespace>_<Subsystem>_pending_timers". They
+	// are both optional.
+	Namespace string
+	Subsystem string
+
+	// ConstLabels are the labels attached to all metrics, which can be used
+	// to distinguish multiple timing wheels.
+	ConstLabels prometheus.Labels
+
+	// LatenessBuckets are the upper bounds (in seconds) of the buckets of
+	// the lateness histogram, in increasing order. If nil, DefLatenessBuckets
+	// is used.
+	//
+	// Since the timing wheel only records the latenesses to a power-of-two
+	// resolution, the count of each bucket only includes the latenesses that
+	// are known to be within its upper bound, and thus may be an underestimate.
+	LatenessBuckets []float64
+}
+
+// Collector is a prometheus.Collector, which collects the statistics of
+// a timing wheel.
+type Collector struct {
+	tw      *timingwheel.TimingWheel
+	buckets []float64
+
+	pending       *prometheus.Desc
+	activeBuckets *prometheus.Desc
+	queueDepth    *prometheus.Desc
+	fired         *prometheus.Desc
+	stopped       *prometheus.Desc
+	expiredOnAdd  *prometheus.Desc
+	lateness      *prometheus.Desc
+}
+
+// New creates an instance of Collector, which collects the statistics of
+// the timing wheel tw.
+func New(tw *timingwheel.TimingWheel, opts Opts) *Collector {
+	desc := func(name, help string, variableLabels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(opts.Namespace, opts.Subsystem, name),
+			help,
+			variableLabels,
+			opts.ConstLabels,
+		)
+	}
+
+	buckets := opts.LatenessBuckets
+	if buckets == nil {
+		buckets = DefLatenessBuckets
+	}
+
+	return &Collector{
+		tw:      tw,
+		buckets: buckets,
+
+		pending:       desc("pending_timers", "Number of timers waiting to expire, by the level of the wheel.", "level"),
+		activeBuckets: desc("active_buckets", "Number of buckets holding timers."),
+		queueDepth:    desc("queue_depth", "Number of buckets in the delay queue."),
+		fired:         desc("fired_total", "Total number of timer expirations."),
+		stopped:       desc("stopped_total", "Total number of timers stopped before they expired."),
+		expiredOnAdd:  desc("expired_on_add_total", "Total number of timers that had already expired when they were added."),
+		lateness:      desc("lateness_seconds", "Time between the expiration of a timer and the start of its task."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.pending
+	ch <- c.activeBuckets
+	ch <- c.queueDepth
+	ch <- c.fired
+	ch <- c.stopped
+	ch <- c.expiredOnAdd
+	ch <- c.lateness
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.tw.Stats()
+
+	for level, pending := range stats.PendingByLevel {
+		ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, float64(pending), strconv.Itoa(level))
+	}
+	ch <- prometheus.MustNewConstMetric(c.activeBuckets, prometheus.GaugeValue, float64(stats.ActiveBuckets))
+	ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(stats.QueueDepth))
+	ch <- prometheus.MustNewConstMetric(c.fired, prometheus.CounterValue, float64(stats.Fired))
+	ch <- prometheus.MustNewConstMetric(c.stopped, prometheus.CounterValue, float64(stats.Stopped))
+	ch <- prometheus.MustNewConstMetric(c.expiredOnAdd, prometheus.CounterValue, float64(stats.ExpiredOnAdd))
+
+	h := stats.Lateness
+	ch <- prometheus.MustNewConstHistogram(c.lateness, h.Count, h.Sum.Seconds(), c.latenessBuckets(h))
+}
+
+// latenessBuckets converts the native buckets of the histogram h into
+// the cumulative counts of the configured buckets.
+func (c *Collector) latenessBuckets(h timingwheel.Histogram) map[float64]uint64 {
+	buckets := make(map[float64]uint64, len(c.buckets))
+	for _, upperBound := range c.buckets {
+		bound := time.Duration(upperBound * float64(time.Second))
+
+		var count uint64
+		for _, b := range h.Buckets {
+			if b.UpperBound > bound {
+				break
+			}
+			count = b.Count
+		}
+		buckets[upperBound] = count
+	}
+	return buckets
+}
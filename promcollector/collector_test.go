@@ -0,0 +1,85 @@
+package promcollector_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+	"github.com/RussellLuo/timingwheel/promcollector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	clock := timingwheel.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tw := timingwheel.NewTimingWheel(time.Millisecond, 20,
+		timingwheel.WithClock(clock),
+		timingwheel.WithInlineTasks(),
+	)
+	tw.Start()
+	defer tw.Stop()
+
+	c := promcollector.New(tw, promcollector.Opts{
+		Namespace:       "app",
+		Subsystem:       "timingwheel",
+		ConstLabels:     prometheus.Labels{"wheel": "test"},
+		LatenessBuckets: []float64{0.001, 0.01},
+	})
+	if problems, err := testutil.CollectAndLint(c); err != nil || len(problems) > 0 {
+		t.Fatalf("Lint: %v, %+v", err, problems)
+	}
+
+	timer := tw.AfterFunc(5*time.Millisecond, func() {})
+	tw.AfterFunc(10*time.Millisecond, func() {})
+	tw.AfterFunc(100*time.Millisecond, func() {})
+	tw.AfterFunc(0, func() {})
+	timer.Stop()
+	clock.Advance(10 * time.Millisecond)
+
+	want := `
+# HELP app_timingwheel_active_buckets Number of buckets holding timers.
+# TYPE app_timingwheel_active_buckets gauge
+app_timingwheel_active_buckets{wheel="test"} 1
+# HELP app_timingwheel_expired_on_add_total Total number of timers that had already expired when they were added.
+# TYPE app_timingwheel_expired_on_add_total counter
+app_timingwheel_expired_on_add_total{wheel="test"} 1
+# HELP app_timingwheel_fired_total Total number of timer expirations.
+# TYPE app_timingwheel_fired_total counter
+app_timingwheel_fired_total{wheel="test"} 2
+# HELP app_timingwheel_lateness_seconds Time between the expiration of a timer and the start of its task.
+# TYPE app_timingwheel_lateness_seconds histogram
+app_timingwheel_lateness_seconds_bucket{wheel="test",le="0.001"} 2
+app_timingwheel_lateness_seconds_bucket{wheel="test",le="0.01"} 2
+app_timingwheel_lateness_seconds_bucket{wheel="test",le="+Inf"} 2
+app_timingwheel_lateness_seconds_sum{wheel="test"} 0
+app_timingwheel_lateness_seconds_count{wheel="test"} 2
+# HELP app_timingwheel_pending_timers Number of timers waiting to expire, by the level of the wheel.
+# TYPE app_timingwheel_pending_timers gauge
+app_timingwheel_pending_timers{level="0",wheel="test"} 0
+app_timingwheel_pending_timers{level="1",wheel="test"} 1
+# HELP app_timingwheel_queue_depth Number of buckets in the delay queue.
+# TYPE app_timingwheel_queue_depth gauge
+app_timingwheel_queue_depth{wheel="test"} 1
+# HELP app_timingwheel_stopped_total Total number of timers stopped before they expired.
+# TYPE app_timingwheel_stopped_total counter
+app_timingwheel_stopped_total{wheel="test"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollector_Register(t *testing.T) {
+	tw := timingwheel.NewTimingWheel(time.Millisecond, 20)
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(promcollector.New(tw, promcollector.Opts{})); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	// One pending_timers for the only level, plus the other six metrics.
+	if n, err := testutil.GatherAndCount(reg); err != nil || n != 7 {
+		t.Fatalf("Got (%+v, %v) != Want (%+v, %v)", n, err, 7, nil)
+	}
+}
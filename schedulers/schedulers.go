@@ -0,0 +1,309 @@
+// Package schedulers provides the common implementations of
+// timingwheel.Scheduler, as well as the combinators to build new
+// schedulers from existing ones.
+//
+// Some schedulers, such as the ones created by Times, Backoff, Jitter,
+// Limit and Union, keep the state of their execution plans. Such a
+// scheduler must not be shared between timers.
+package schedulers
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+	"github.com/RussellLuo/timingwheel/cron"
+)
+
+// Every returns a scheduler whose times are spaced d apart, starting
+// from d after the time the timer is created.
+func Every(d time.Duration) timingwheel.Scheduler {
+	if d <= 0 {
+		panic(errors.New("non-positive interval for Every"))
+	}
+	return every{d: d}
+}
+
+type every struct {
+	d time.Duration
+}
+
+func (s every) Next(prev time.Time) time.Time {
+	return prev.Add(s.d)
+}
+
+// EveryFrom returns a scheduler whose times are spaced d apart, starting
+// from start, i.e. the times are start, start+d, start+2d, and so on. The
+// times before the time the timer is created are skipped.
+//
+// Unlike Every, the times of EveryFrom do not depend on when the timer is
+// created, or when the previous execution happened.
+func EveryFrom(start time.Time, d time.Duration) timingwheel.Scheduler {
+	if d <= 0 {
+		panic(errors.New("non-positive interval for EveryFrom"))
+	}
+	return everyFrom{start: start, d: d}
+}
+
+type everyFrom struct {
+	start time.Time
+	d     time.Duration
+}
+
+func (s everyFrom) Next(prev time.Time) time.Time {
+	if prev.Before(s.start) {
+		return s.start
+	}
+	n := prev.Sub(s.start) / s.d
+	return s.start.Add((n + 1) * s.d)
+}
+
+// Daily returns a scheduler whose times are the given time of day, in
+// the time zone loc, every day. It panics if the time of day is invalid.
+//
+// The times skipped or repeated by the wall clock due to daylight saving
+// time are handled as described in timingwheel.ZonedScheduler.
+func Daily(hour, minute, second int, loc *time.Location) timingwheel.ZonedScheduler {
+	s, err := cron.ParseInLocation(fmt.Sprintf("%d %d %d * * *", second, minute, hour), loc)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// InLocation returns a scheduler whose times are the times of s, but which
+// is a timingwheel.ZonedScheduler in the time zone loc, i.e. s is given the
+// times in loc, thus s can do the calendar arithmetic in the wall clock of loc.
+//
+// For example, the following scheduler executes at the same wall-clock time
+// every day, across the daylight saving time transitions:
+//
+//	schedulers.InLocation(daily, berlin)
+//
+// where daily.Next returns prev.AddDate(0, 0, 1). Note that it is up to s
+// how to handle the times skipped or repeated by the wall clock; the times
+// normalized by time.Date are used in the example above.
+func InLocation(s timingwheel.Scheduler, loc *time.Location) timingwheel.ZonedScheduler {
+	return inLocation{s: s, loc: loc}
+}
+
+type inLocation struct {
+	s   timingwheel.Scheduler
+	loc *time.Location
+}
+
+func (s inLocation) Next(prev time.Time) time.Time {
+	return s.s.Next(prev.In(s.loc))
+}
+
+func (s inLocation) Location() *time.Location {
+	return s.loc
+}
+
+// Times returns a scheduler whose times are spaced d apart, like Every,
+// but which ends after n times.
+func Times(n int, d time.Duration) timingwheel.Scheduler {
+	return Limit(Every(d), n)
+}
+
+// Backoff returns a scheduler whose intervals grow exponentially: the first
+// interval is initial, and each subsequent one is multiplier times the
+// previous one, but never exceeds max.
+func Backoff(initial, max time.Duration, multiplier float64) timingwheel.Scheduler {
+	if initial <= 0 || max < initial {
+		panic(errors.New("bad intervals for Backoff"))
+	}
+	if multiplier < 1 {
+		panic(errors.New("multiplier less than 1 for Backoff"))
+	}
+	return &backoff{
+		next:       initial,
+		max:        max,
+		multiplier: multiplier,
+	}
+}
+
+type backoff struct {
+	next       time.Duration
+	max        time.Duration
+	multiplier float64
+}
+
+func (s *backoff) Next(prev time.Time) time.Time {
+	d := s.next
+	if next := float64(s.next) * s.multiplier; next < float64(s.max) {
+		s.next = time.Duration(next)
+	} else {
+		s.next = s.max
+	}
+	return prev.Add(d)
+}
+
+// Jitter returns a scheduler which delays each time of s by a random
+// duration in [0, max).
+//
+// The jitter does not accumulate, i.e. the times of s are computed as if
+// there were no jitter.
+func Jitter(s timingwheel.Scheduler, max time.Duration) timingwheel.Scheduler {
+	if max <= 0 {
+		panic(errors.New("non-positive max for Jitter"))
+	}
+	return &jitter{s: s, max: max}
+}
+
+type jitter struct {
+	s   timingwheel.Scheduler
+	max time.Duration
+
+	// The last time of s, and the jittered one.
+	base, jittered time.Time
+}
+
+func (s *jitter) Next(prev time.Time) time.Time {
+	if !s.jittered.IsZero() && prev.Equal(s.jittered) {
+		// Continue the execution plan of s without the jitter.
+		prev = s.base
+	}
+
+	next := nextTime(s.s, prev)
+	if next.IsZero() {
+		return next
+	}
+	s.base = next
+	s.jittered = next.Add(time.Duration(rand.Int63n(int64(s.max))))
+	return s.jittered
+}
+
+// Limit returns a scheduler which ends after the first n times of s.
+func Limit(s timingwheel.Scheduler, n int) timingwheel.Scheduler {
+	return &limit{s: s, n: n}
+}
+
+type limit struct {
+	s timingwheel.Scheduler
+	n int
+}
+
+func (s *limit) Next(prev time.Time) time.Time {
+	if s.n <= 0 {
+		return time.Time{}
+	}
+	s.n--
+	return nextTime(s.s, prev)
+}
+
+// Until returns a scheduler which ends once the times of s are after
+// deadline.
+func Until(s timingwheel.Scheduler, deadline time.Time) timingwheel.Scheduler {
+	return until{s: s, deadline: deadline}
+}
+
+type until struct {
+	s        timingwheel.Scheduler
+	deadline time.Time
+}
+
+func (s until) Next(prev time.Time) time.Time {
+	next := nextTime(s.s, prev)
+	if next.After(s.deadline) {
+		return time.Time{}
+	}
+	return next
+}
+
+// nextTime returns the next time of s after prev, which is converted into the
+// location of s if s is a ZonedScheduler.
+func nextTime(s timingwheel.Scheduler, prev time.Time) time.Time {
+	if zs, ok := s.(timingwheel.ZonedScheduler); ok {
+		prev = prev.In(zs.Location())
+	}
+	return s.Next(prev)
+}
+
+// Union returns a scheduler whose times are the times of any of ss, i.e.
+// the execution plans of ss are merged into one. The same times of
+// different schedulers are merged into one time.
+//
+// Each scheduler of ss keeps its own execution plan, as if it were used
+// by a timer alone.
+func Union(ss ...timingwheel.Scheduler) timingwheel.Scheduler {
+	return &union{ss: ss, next: make([]time.Time, len(ss))}
+}
+
+type union struct {
+	ss      []timingwheel.Scheduler
+	next    []time.Time // the zero time means the execution plan has ended
+	started bool
+}
+
+func (s *union) Next(prev time.Time) time.Time {
+	var earliest time.Time
+	for i, sub := range s.ss {
+		if !s.started {
+			s.next[i] = nextTime(sub, prev)
+		} else {
+			// Advance the execution plan of sub until it's after prev.
+			for !s.next[i].IsZero() && !s.next[i].After(prev) {
+				s.next[i] = nextTime(sub, s.next[i])
+			}
+		}
+
+		if next := s.next[i]; !next.IsZero() && (earliest.IsZero() || next.Before(earliest)) {
+			earliest = next
+		}
+	}
+	s.started = true
+	return earliest
+}
+
+// maxIntersectIterations is the maximum number of iterations to find the
+// next common time of the schedulers, beyond which the intersection is
+// considered to have no next time.
+const maxIntersectIterations = 1000
+
+// Intersect returns a scheduler whose times are the common times of all of ss.
+//
+// Intersect is meant for the schedulers whose times do not depend on when
+// the previous execution happened, such as the ones created by EveryFrom,
+// or parsed from cron expressions. Each scheduler of ss is asked for the next
+// time after an arbitrary time, thus it must be stateless.
+func Intersect(ss ...timingwheel.Scheduler) timingwheel.Scheduler {
+	return intersect{ss: ss}
+}
+
+type intersect struct {
+	ss []timingwheel.Scheduler
+}
+
+func (s intersect) Next(prev time.Time) time.Time {
+	if len(s.ss) == 0 {
+		return time.Time{}
+	}
+
+	for i := 0; i < maxIntersectIterations; i++ {
+		var latest time.Time
+		common := true
+		for _, sub := range s.ss {
+			next := nextTime(sub, prev)
+			if next.IsZero() {
+				return next
+			}
+			if !latest.IsZero() && !next.Equal(latest) {
+				common = false
+			}
+			if next.After(latest) {
+				latest = next
+			}
+		}
+		if common {
+			return latest
+		}
+
+		// Find the next times from the latest one, which is the earliest
+		// possible common time.
+		prev = latest.Add(-1)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,314 @@
+package schedulers_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+	"github.com/RussellLuo/timingwheel/cron"
+	"github.com/RussellLuo/timingwheel/schedulers"
+)
+
+var start = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// plan returns at most n times of s, as if s were used by a timer created
+// at start, and each execution happened exactly at its scheduled time.
+func plan(s timingwheel.Scheduler, n int) (times []time.Duration) {
+	prev := start
+	for i := 0; i < n; i++ {
+		next := s.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		times = append(times, next.Sub(start))
+		prev = next
+	}
+	return
+}
+
+func durations(ds ...time.Duration) []time.Duration {
+	return ds
+}
+
+func TestSchedulers(t *testing.T) {
+	s := time.Second
+	cases := []struct {
+		name string
+		s    timingwheel.Scheduler
+		want []time.Duration
+		ends bool // whether the execution plan ends after want
+	}{
+		{
+			name: "every",
+			s:    schedulers.Every(s),
+			want: durations(1*s, 2*s, 3*s, 4*s, 5*s),
+		},
+		{
+			name: "every from a future start",
+			s:    schedulers.EveryFrom(start.Add(10*s), 5*s),
+			want: durations(10*s, 15*s, 20*s, 25*s, 30*s),
+		},
+		{
+			name: "every from a past start",
+			s:    schedulers.EveryFrom(start.Add(-7*s), 5*s),
+			want: durations(3*s, 8*s, 13*s, 18*s, 23*s),
+		},
+		{
+			name: "every from now",
+			s:    schedulers.EveryFrom(start, 5*s),
+			want: durations(5*s, 10*s, 15*s, 20*s, 25*s),
+		},
+		{
+			name: "times",
+			s:    schedulers.Times(3, s),
+			want: durations(1*s, 2*s, 3*s),
+			ends: true,
+		},
+		{
+			name: "times zero",
+			s:    schedulers.Times(0, s),
+			want: nil,
+			ends: true,
+		},
+		{
+			name: "backoff",
+			s:    schedulers.Backoff(s, 10*s, 2),
+			want: durations(1*s, 3*s, 7*s, 15*s, 25*s),
+		},
+		{
+			name: "backoff without growth",
+			s:    schedulers.Backoff(s, 10*s, 1),
+			want: durations(1*s, 2*s, 3*s, 4*s, 5*s),
+		},
+		{
+			name: "limit",
+			s:    schedulers.Limit(schedulers.Every(2*s), 2),
+			want: durations(2*s, 4*s),
+			ends: true,
+		},
+		{
+			name: "until",
+			s:    schedulers.Until(schedulers.Every(2*s), start.Add(6*s)),
+			want: durations(2*s, 4*s, 6*s),
+			ends: true,
+		},
+		{
+			name: "until a past deadline",
+			s:    schedulers.Until(schedulers.Every(2*s), start),
+			want: nil,
+			ends: true,
+		},
+		{
+			name: "union",
+			s:    schedulers.Union(schedulers.Every(2*s), schedulers.Every(3*s)),
+			want: durations(2*s, 3*s, 4*s, 6*s, 8*s, 9*s, 10*s),
+		},
+		{
+			name: "union with ended schedulers",
+			s:    schedulers.Union(schedulers.Times(2, 2*s), schedulers.Times(1, 3*s), schedulers.Times(0, s)),
+			want: durations(2*s, 3*s, 4*s),
+			ends: true,
+		},
+		{
+			name: "union of nothing",
+			s:    schedulers.Union(),
+			want: nil,
+			ends: true,
+		},
+		{
+			name: "intersect",
+			s:    schedulers.Intersect(schedulers.EveryFrom(start, 2*s), schedulers.EveryFrom(start, 3*s)),
+			want: durations(6*s, 12*s, 18*s, 24*s, 30*s),
+		},
+		{
+			name: "intersect with cron",
+			s: schedulers.Intersect(
+				cron.MustParse("CRON_TZ=UTC 0 0 13 * *"),
+				cron.MustParse("CRON_TZ=UTC 0 0 * * FRI"),
+			),
+			// Friday the 13th.
+			want: durations(
+				time.Date(2020, 3, 13, 0, 0, 0, 0, time.UTC).Sub(start),
+				time.Date(2020, 11, 13, 0, 0, 0, 0, time.UTC).Sub(start),
+				time.Date(2021, 8, 13, 0, 0, 0, 0, time.UTC).Sub(start),
+			),
+		},
+		{
+			name: "intersect without common times",
+			s:    schedulers.Intersect(schedulers.EveryFrom(start, 2*s), schedulers.EveryFrom(start.Add(s), 2*s)),
+			want: nil,
+			ends: true,
+		},
+		{
+			name: "intersect of nothing",
+			s:    schedulers.Intersect(),
+			want: nil,
+			ends: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			n := len(c.want)
+			if c.ends {
+				// Make sure that there are no more times.
+				n++
+			}
+			if got := plan(c.s, n); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("Got (%+v) != Want (%+v)", got, c.want)
+			}
+		})
+	}
+}
+
+func TestJitter(t *testing.T) {
+	s := schedulers.Jitter(schedulers.Every(10*time.Second), time.Second)
+
+	prev := start
+	for i := 1; i <= 100; i++ {
+		next := s.Next(prev)
+
+		// The jitter does not accumulate.
+		base := start.Add(time.Duration(i) * 10 * time.Second)
+		if next.Before(base) || !next.Before(base.Add(time.Second)) {
+			t.Fatalf("Time %d: Got (%+v) != Want within [%v, %v)", i, next, base, base.Add(time.Second))
+		}
+		prev = next
+	}
+}
+
+func TestJitter_End(t *testing.T) {
+	s := schedulers.Jitter(schedulers.Times(2, time.Second), time.Millisecond)
+	if got := plan(s, 3); len(got) != 2 {
+		t.Fatalf("Got (%+v) times != Want (%+v)", len(got), 2)
+	}
+}
+
+func TestSchedulers_Panic(t *testing.T) {
+	cases := map[string]func(){
+		"every":              func() { schedulers.Every(0) },
+		"every from":         func() { schedulers.EveryFrom(start, -time.Second) },
+		"backoff initial":    func() { schedulers.Backoff(0, time.Second, 2) },
+		"backoff max":        func() { schedulers.Backoff(time.Second, time.Millisecond, 2) },
+		"backoff multiplier": func() { schedulers.Backoff(time.Second, time.Minute, 0.5) },
+		"jitter":             func() { schedulers.Jitter(schedulers.Every(time.Second), 0) },
+	}
+	for name, f := range cases {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Did not panic")
+				}
+			}()
+			f()
+		})
+	}
+}
+
+func TestSchedulers_ScheduleFunc(t *testing.T) {
+	clock := timingwheel.NewFakeClock(start)
+	tw := timingwheel.NewTimingWheel(time.Millisecond, 20,
+		timingwheel.WithClock(clock),
+		timingwheel.WithInlineTasks(),
+	)
+	tw.Start()
+	defer tw.Stop()
+
+	var fired []time.Duration
+	tw.ScheduleFunc(schedulers.Backoff(time.Second, 4*time.Second, 2), func() {
+		fired = append(fired, clock.Now().Sub(start))
+	})
+	tw.ScheduleFunc(schedulers.Times(2, 1500*time.Millisecond), func() {
+		fired = append(fired, clock.Now().Sub(start))
+	})
+
+	clock.Advance(10 * time.Second)
+
+	want := durations(
+		time.Second,
+		1500*time.Millisecond,
+		3*time.Second,
+		3*time.Second,
+		7*time.Second,
+	)
+	if !reflect.DeepEqual(fired, want) {
+		t.Fatalf("Got (%+v) != Want (%+v)", fired, want)
+	}
+}
+
+// addDate executes at the same wall-clock time every day, by the calendar
+// arithmetic in the location of the given time.
+type addDate struct{}
+
+func (addDate) Next(prev time.Time) time.Time {
+	return prev.AddDate(0, 0, 1)
+}
+
+func TestSchedulers_DST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("Failed to load location: %v", err)
+	}
+
+	utc := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2021, month, day, hour, min, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		name string
+		s    timingwheel.Scheduler
+		prev time.Time
+		want []time.Time
+	}{
+		{
+			name: "daily across spring forward",
+			s:    schedulers.Daily(9, 0, 0, berlin),
+			prev: utc(time.March, 27, 0, 0),
+			want: []time.Time{utc(time.March, 27, 8, 0), utc(time.March, 28, 7, 0), utc(time.March, 29, 7, 0)},
+		},
+		{
+			name: "daily at a skipped time",
+			s:    schedulers.Daily(2, 30, 0, berlin),
+			prev: utc(time.March, 27, 0, 0),
+			// 02:30 CET, 03:00 CEST (the transition), 02:30 CEST.
+			want: []time.Time{utc(time.March, 27, 1, 30), utc(time.March, 28, 1, 0), utc(time.March, 29, 0, 30)},
+		},
+		{
+			name: "daily at a repeated time",
+			s:    schedulers.Daily(2, 30, 0, berlin),
+			prev: utc(time.October, 30, 0, 0),
+			// 02:30 CEST, 02:30 CEST (the first occurrence), 02:30 CET.
+			want: []time.Time{utc(time.October, 30, 0, 30), utc(time.October, 31, 0, 30), utc(time.November, 1, 1, 30)},
+		},
+		{
+			name: "in location across fall back",
+			s:    schedulers.InLocation(addDate{}, berlin),
+			prev: utc(time.October, 30, 7, 0),
+			want: []time.Time{utc(time.October, 31, 8, 0), utc(time.November, 1, 8, 0)},
+		},
+		{
+			name: "in location within a combinator",
+			s:    schedulers.Limit(schedulers.InLocation(addDate{}, berlin), 2),
+			prev: utc(time.March, 27, 8, 0),
+			want: []time.Time{utc(time.March, 28, 7, 0), utc(time.March, 29, 7, 0), {}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			prev := c.prev
+			for i, want := range c.want {
+				// Like a timer, pass the times in UTC to non-zoned schedulers.
+				prev = prev.UTC()
+				if zs, ok := c.s.(timingwheel.ZonedScheduler); ok {
+					prev = prev.In(zs.Location())
+				}
+				got := c.s.Next(prev)
+				if !got.Equal(want) {
+					t.Fatalf("Time %d: Got (%+v) != Want (%+v)", i, got, want)
+				}
+				prev = got
+			}
+		})
+	}
+}
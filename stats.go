@@ -0,0 +1,173 @@
+package timingwheel
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// latenessBuckets is the number of the buckets of the lateness histogram.
+// The i-th bucket counts the latenesses within [2^(i-1), 2^i) nanoseconds,
+// except for the 0-th bucket, which counts the zero latenesses.
+const latenessBuckets = 64
+
+// wheelStats holds the counters of a timing wheel, which are all updated
+// and read atomically.
+type wheelStats struct {
+	// 64-bit atomic operations require 64-bit alignment, but 32-bit
+	// compilers do not ensure it. Since wheelStats is always allocated
+	// on its own, and all its fields are 64-bit, they are all aligned.
+	fired        uint64
+	stopped      uint64
+	expiredOnAdd uint64
+
+	maxLateness int64 // in nanoseconds
+	latenessSum int64 // in nanoseconds
+	lateness    [latenessBuckets]uint64
+}
+
+// observeLateness records that a task started lateness nanoseconds after
+// the expiration of its timer.
+func (s *wheelStats) observeLateness(lateness int64) {
+	if lateness < 0 {
+		// The task may start a little early, since the expirations of
+		// the buckets are truncated to the tick.
+		lateness = 0
+	}
+
+	atomic.AddInt64(&s.latenessSum, lateness)
+	atomic.AddUint64(&s.lateness[bits.Len64(uint64(lateness))], 1)
+
+	for {
+		max := atomic.LoadInt64(&s.maxLateness)
+		if lateness <= max || atomic.CompareAndSwapInt64(&s.maxLateness, max, lateness) {
+			return
+		}
+	}
+}
+
+// Stats is a snapshot of the statistics of a TimingWheel.
+//
+// Since the statistics are collected without stopping the timing wheel,
+// the values of a snapshot may be slightly inconsistent with each other.
+type Stats struct {
+	// Pending is the number of timers that are waiting to expire.
+	Pending int64
+	// PendingByLevel is the number of pending timers in each level of the
+	// hierarchical timing wheels, starting from the lowest level.
+	PendingByLevel []int64
+
+	// ActiveBuckets is the number of buckets that hold timers, or are
+	// about to be flushed.
+	ActiveBuckets int
+	// QueueDepth is the number of buckets in the delay queue.
+	QueueDepth int
+
+	// Fired is the number of times that timers have expired.
+	Fired uint64
+	// Stopped is the number of timers that have been stopped, either
+	// explicitly or by their contexts, before they expired.
+	Stopped uint64
+	// ExpiredOnAdd is the number of timers that had already expired when
+	// they were added (or reset), and thus fired immediately.
+	ExpiredOnAdd uint64
+
+	// MaxLateness is the maximum time between the expiration of a timer
+	// and the start of its task.
+	MaxLateness time.Duration
+	// P99Lateness is the 99th percentile of the latenesses. It is an
+	// approximation, which is accurate to within a factor of two.
+	P99Lateness time.Duration
+	// Lateness is the distribution of the latenesses.
+	Lateness Histogram
+}
+
+// Histogram is a distribution of durations.
+type Histogram struct {
+	// Count is the number of the observed durations.
+	Count uint64
+	// Sum is the sum of the observed durations.
+	Sum time.Duration
+	// Buckets are the cumulative counts of the observed durations,
+	// in increasing order of their upper bounds.
+	Buckets []HistogramBucket
+}
+
+// HistogramBucket is a bucket of a Histogram.
+type HistogramBucket struct {
+	// UpperBound is the inclusive upper bound of the bucket.
+	UpperBound time.Duration
+	// Count is the number of the observed durations that are less than
+	// or equal to UpperBound.
+	Count uint64
+}
+
+// Quantile returns the approximate q-quantile (0 <= q <= 1) of the observed
+// durations, which is the upper bound of the first bucket that covers it.
+// It returns 0 if there are no observed durations.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := uint64(q * float64(h.Count))
+	if rank == 0 {
+		rank = 1
+	}
+	for _, b := range h.Buckets {
+		if b.Count >= rank {
+			return b.UpperBound
+		}
+	}
+	return h.Buckets[len(h.Buckets)-1].UpperBound
+}
+
+// Stats returns a snapshot of the statistics of the timing wheel.
+func (tw *TimingWheel) Stats() Stats {
+	s := Stats{
+		QueueDepth:   tw.queue.Len(),
+		Fired:        atomic.LoadUint64(&tw.stats.fired),
+		Stopped:      atomic.LoadUint64(&tw.stats.stopped),
+		ExpiredOnAdd: atomic.LoadUint64(&tw.stats.expiredOnAdd),
+		MaxLateness:  time.Duration(atomic.LoadInt64(&tw.stats.maxLateness)),
+		Lateness:     tw.stats.latenessHistogram(),
+	}
+	s.P99Lateness = s.Lateness.Quantile(0.99)
+	if s.P99Lateness > s.MaxLateness {
+		s.P99Lateness = s.MaxLateness
+	}
+
+	for w := tw; w != nil; w = (*TimingWheel)(atomic.LoadPointer(&w.overflowWheel)) {
+		pending := atomic.LoadInt64(w.pending)
+		s.Pending += pending
+		s.PendingByLevel = append(s.PendingByLevel, pending)
+
+		for _, b := range w.buckets {
+			if b.Expiration() != -1 {
+				s.ActiveBuckets++
+			}
+		}
+	}
+
+	return s
+}
+
+// latenessHistogram returns the histogram of the latenesses.
+func (s *wheelStats) latenessHistogram() Histogram {
+	h := Histogram{
+		Sum: time.Duration(atomic.LoadInt64(&s.latenessSum)),
+	}
+
+	// Only report the buckets up to the one holding the maximum lateness.
+	last := bits.Len64(uint64(atomic.LoadInt64(&s.maxLateness)))
+	h.Buckets = make([]HistogramBucket, 0, last+1)
+	for i := 0; i <= last; i++ {
+		h.Count += atomic.LoadUint64(&s.lateness[i])
+		h.Buckets = append(h.Buckets, HistogramBucket{
+			UpperBound: time.Duration(uint64(1)<<uint(i) - 1),
+			Count:      h.Count,
+		})
+	}
+
+	return h
+}
@@ -0,0 +1,89 @@
+package timingwheel_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+)
+
+func TestTimingWheel_Stats(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), timingwheel.WithInlineTasks())
+
+	stats := tw.Stats()
+	if stats.Pending != 0 || stats.Fired != 0 || stats.QueueDepth != 0 {
+		t.Fatalf("Got (%+v) != Want an empty Stats", stats)
+	}
+
+	// Level 0 holds the timers within 20ms, level 1 within 400ms, and level 2 within 8s.
+	t1 := tw.AfterFunc(5*time.Millisecond, func() {})
+	tw.AfterFunc(10*time.Millisecond, func() {})
+	tw.AfterFunc(100*time.Millisecond, func() {})
+	tw.AfterFunc(time.Second, func() {})
+	tw.AfterFunc(0, func() {})
+
+	stats = tw.Stats()
+	if stats.Pending != 4 {
+		t.Fatalf("Pending: Got (%+v) != Want (%+v)", stats.Pending, 4)
+	}
+	if want := []int64{2, 1, 1}; !reflect.DeepEqual(stats.PendingByLevel, want) {
+		t.Fatalf("PendingByLevel: Got (%+v) != Want (%+v)", stats.PendingByLevel, want)
+	}
+	if stats.ActiveBuckets != 4 || stats.QueueDepth != 4 {
+		t.Fatalf("ActiveBuckets, QueueDepth: Got (%+v, %+v) != Want (%+v, %+v)", stats.ActiveBuckets, stats.QueueDepth, 4, 4)
+	}
+	if stats.ExpiredOnAdd != 1 || stats.Fired != 1 {
+		t.Fatalf("ExpiredOnAdd, Fired: Got (%+v, %+v) != Want (%+v, %+v)", stats.ExpiredOnAdd, stats.Fired, 1, 1)
+	}
+
+	t1.Stop()
+	t1.Stop() // already stopped
+	clock.Advance(100 * time.Millisecond)
+
+	stats = tw.Stats()
+	if stats.Stopped != 1 || stats.Fired != 3 {
+		t.Fatalf("Stopped, Fired: Got (%+v, %+v) != Want (%+v, %+v)", stats.Stopped, stats.Fired, 1, 3)
+	}
+	if want := []int64{0, 0, 1}; !reflect.DeepEqual(stats.PendingByLevel, want) {
+		t.Fatalf("PendingByLevel: Got (%+v) != Want (%+v)", stats.PendingByLevel, want)
+	}
+
+	// The fake clock fires the timers exactly at their expirations.
+	if stats.Lateness.Count != 3 || stats.MaxLateness != 0 || stats.P99Lateness != 0 {
+		t.Fatalf("Lateness: Got (%+v) != Want no lateness", stats.Lateness)
+	}
+}
+
+func TestHistogram_Quantile(t *testing.T) {
+	h := timingwheel.Histogram{
+		Count: 100,
+		Buckets: []timingwheel.HistogramBucket{
+			{UpperBound: 0, Count: 50},
+			{UpperBound: 1, Count: 90},
+			{UpperBound: 3, Count: 99},
+			{UpperBound: 7, Count: 100},
+		},
+	}
+
+	cases := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0, 0},
+		{0.5, 0},
+		{0.9, 1},
+		{0.95, 3},
+		{0.99, 3},
+		{1, 7},
+	}
+	for _, c := range cases {
+		if got := h.Quantile(c.q); got != c.want {
+			t.Errorf("Quantile(%v): Got (%+v) != Want (%+v)", c.q, got, c.want)
+		}
+	}
+
+	if got := (timingwheel.Histogram{}).Quantile(0.99); got != 0 {
+		t.Errorf("Empty: Got (%+v) != Want (%+v)", got, 0)
+	}
+}
@@ -0,0 +1,108 @@
+package timingwheel
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Ticker holds a channel that delivers “ticks” of a clock at intervals.
+type Ticker struct {
+	// 64-bit atomic operations require 64-bit alignment, but 32-bit
+	// compilers do not ensure it. So we must keep the 64-bit field
+	// as the first field of the struct.
+	period int64 // in nanoseconds
+
+	// C is the channel on which the ticks are delivered. It is only set for
+	// tickers created by NewTicker.
+	C <-chan time.Time
+
+	t *Timer
+}
+
+// NewTicker returns a new Ticker containing a channel that will send the
+// current time on the channel after each tick. The period of the ticks is
+// specified by the duration argument. The duration d must be greater than zero;
+// if not, NewTicker will panic.
+//
+// Like the standard time.Ticker (https://golang.org/pkg/time/#Ticker), the
+// ticks are scheduled at fixed intervals from the start, thus they will not
+// drift, and the ticker will drop ticks to make up for slow receivers.
+func (tw *TimingWheel) NewTicker(d time.Duration) *Ticker {
+	c := make(chan time.Time, 1)
+	tk := tw.newTicker(d, func() {
+		select {
+		case c <- tw.clock.Now():
+		default:
+		}
+	}, true)
+	tk.C = c
+	tw.submit(tk.t)
+	return tk
+}
+
+// TickFunc calls f in its own goroutine after each tick, whose period is
+// specified by the duration argument. It returns a Ticker, which has no
+// channel, that can be used to cancel the calls using its Stop method.
+// The duration d must be greater than zero; if not, TickFunc will panic.
+//
+// Like NewTicker, TickFunc will not drift, and it will skip the ticks that
+// are missed if the timing wheel falls behind.
+func (tw *TimingWheel) TickFunc(d time.Duration, f func()) *Ticker {
+	tk := tw.newTicker(d, f, false)
+	tw.submit(tk.t)
+	return tk
+}
+
+func (tw *TimingWheel) newTicker(d time.Duration, task func(), inline bool) *Ticker {
+	if d <= 0 {
+		panic(errors.New("non-positive interval for ticker"))
+	}
+
+	tk := &Ticker{period: int64(d)}
+	tk.t = &Timer{
+		id:         tw.nextTimerID(),
+		expiration: timeToNs(tw.clock.Now().UTC().Add(d)),
+		task:       task,
+		inline:     inline,
+		reschedule: tk.next,
+		tw:         tw,
+	}
+	return tk
+}
+
+// next returns the first tick after the current time of the timing wheel.
+func (tk *Ticker) next(expiration int64) (int64, bool) {
+	tw := tk.t.tw
+	period := atomic.LoadInt64(&tk.period)
+
+	next := expiration + period
+	if currentTime := atomic.LoadInt64(&tw.currentTime); next-currentTime < tw.tick {
+		// Drop the ticks that have been missed.
+		missed := (currentTime + tw.tick - next + period - 1) / period
+		next += missed * period
+	}
+	return next, true
+}
+
+// Stop turns off the ticker. After Stop, no more ticks will be sent.
+//
+// Stop does not close the channel, to prevent a concurrent goroutine reading
+// from the channel from seeing an erroneous "tick". For tickers created by
+// TickFunc, Stop does not wait for any call of f that has been started to
+// complete before returning.
+func (tk *Ticker) Stop() {
+	tk.t.Stop()
+}
+
+// Reset stops the ticker and resets its period to the specified duration.
+// The next tick will arrive after the new period elapses. The duration d
+// must be greater than zero; if not, Reset will panic.
+func (tk *Ticker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic(errors.New("non-positive interval for Ticker.Reset"))
+	}
+
+	atomic.StoreInt64(&tk.period, int64(d))
+	tk.t.Reset(d)
+}
@@ -0,0 +1,86 @@
+package timingwheel_test
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimingWheel_NewTicker(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock, tw := newFakeWheel(t, start)
+
+	tk := tw.NewTicker(30 * time.Millisecond)
+	defer tk.Stop()
+
+	for i := 1; i <= 5; i++ {
+		clock.Advance(30 * time.Millisecond)
+
+		want := start.Add(time.Duration(i) * 30 * time.Millisecond)
+		select {
+		case got := <-tk.C:
+			if !got.Equal(want) {
+				t.Fatalf("Tick %d: want %s, got %s", i, want, got)
+			}
+		default:
+			t.Fatalf("Tick %d: did not fire", i)
+		}
+	}
+}
+
+func TestTicker_SlowReceiver(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock, tw := newFakeWheel(t, start)
+
+	tk := tw.NewTicker(10 * time.Millisecond)
+	defer tk.Stop()
+
+	// Nobody receives from tk.C during the 5 ticks.
+	clock.Advance(50 * time.Millisecond)
+
+	// Only the first tick is buffered, and the others are dropped.
+	if got, want := <-tk.C, start.Add(10*time.Millisecond); !got.Equal(want) {
+		t.Fatalf("Tick: want %s, got %s", want, got)
+	}
+	select {
+	case got := <-tk.C:
+		t.Fatalf("Got unexpected tick %s", got)
+	default:
+	}
+
+	// The ticker keeps ticking at the fixed rate.
+	clock.Advance(10 * time.Millisecond)
+	if got, want := <-tk.C, start.Add(60*time.Millisecond); !got.Equal(want) {
+		t.Fatalf("Tick: want %s, got %s", want, got)
+	}
+}
+
+func TestTicker_StopAndReset(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock, tw := newFakeWheel(t, start)
+
+	ticks := make(chan time.Time, 10)
+	tk := tw.TickFunc(10*time.Millisecond, func() {
+		ticks <- clock.Now()
+	})
+
+	clock.Advance(10 * time.Millisecond)
+	<-ticks
+
+	tk.Reset(25 * time.Millisecond)
+	clock.Advance(25 * time.Millisecond)
+	if got, want := <-ticks, start.Add(35*time.Millisecond); !got.Equal(want) {
+		t.Fatalf("Tick: want %s, got %s", want, got)
+	}
+	clock.Advance(25 * time.Millisecond)
+	if got, want := <-ticks, start.Add(60*time.Millisecond); !got.Equal(want) {
+		t.Fatalf("Tick: want %s, got %s", want, got)
+	}
+
+	tk.Stop()
+	clock.Advance(time.Second)
+	select {
+	case got := <-ticks:
+		t.Fatalf("Got unexpected tick %s after Stop", got)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
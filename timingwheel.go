@@ -1,7 +1,12 @@
 package timingwheel
 
 import (
+	"context"
 	"errors"
+	"math"
+	"runtime/debug"
+	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
@@ -11,12 +16,14 @@ import (
 
 // TimingWheel is an implementation of Hierarchical Timing Wheels.
 type TimingWheel struct {
-	tick      int64 // in milliseconds
+	tick      int64 // in nanoseconds
 	wheelSize int64
 
-	interval    int64 // in milliseconds
-	currentTime int64 // in milliseconds
+	interval    int64 // in nanoseconds
+	currentTime int64 // in nanoseconds
+	lastTimerID uint64
 	buckets     []*bucket
+	pending     *int64 // the number of the pending timers, shared by the buckets
 	queue       *delayqueue.DelayQueue
 
 	// The higher-level overflow wheel.
@@ -24,53 +31,229 @@ type TimingWheel struct {
 	// NOTE: This field may be updated and read concurrently, through Add().
 	overflowWheel unsafe.Pointer // type: *TimingWheel
 
+	// The source of time, which is only used by the lowest-level wheel.
+	clock Clock
+
+	// Whether the timing wheel has been shut down, in which case no new
+	// timers will be accepted. Only used by the lowest-level wheel.
+	closed int32
+
+	// The executor of the timers' tasks, and the tasks that are running
+	// (or waiting to run) in the executor. Only used by the lowest-level wheel.
+	executor Executor
+	tasks    taskGroup
+
+	// Whether to execute all tasks inline, and the handler, as well as its
+	// threshold, for the inline tasks that block the timing wheel for too
+	// long. Only used by the lowest-level wheel.
+	inline              bool
+	slowInline          func(t *Timer, elapsed time.Duration)
+	slowInlineThreshold time.Duration
+
+	// The handler of the panics raised by the tasks. Only used by the
+	// lowest-level wheel.
+	panicHandler func(Panic)
+
+	// The statistics of the timing wheel, and the observer of the timers'
+	// lifecycle events. Only used by the lowest-level wheel.
+	stats    *wheelStats
+	observer Observer
+
+	// The index of the keyed timers. Only used by the lowest-level wheel.
+	keys keyIndex
+
+	// The events collected during flushing, which is only accessed by the
+	// goroutine driving the timing wheel.
+	events []timerEvent
+
 	exitC     chan struct{}
+	stopOnce  sync.Once
 	waitGroup waitGroupWrapper
 }
 
-// NewTimingWheel creates an instance of TimingWheel with the given tick and wheelSize.
-func NewTimingWheel(tick time.Duration, wheelSize int64) *TimingWheel {
-	tickMs := int64(tick / time.Millisecond)
-	if tickMs <= 0 {
-		panic(errors.New("tick must be greater than or equal to 1ms"))
+// Option is a function that configures a TimingWheel.
+type Option func(*TimingWheel)
+
+// WithClock sets the source of time of the timing wheel, which defaults
+// to the system clock.
+func WithClock(c Clock) Option {
+	return func(tw *TimingWheel) {
+		tw.clock = c
 	}
+}
 
-	startMs := timeToMs(time.Now().UTC())
+// WithExecutor sets the executor of the timers' tasks, which defaults to
+// executing each task in its own goroutine.
+func WithExecutor(e Executor) Option {
+	return func(tw *TimingWheel) {
+		tw.executor = e
+	}
+}
+
+// WithInlineTasks makes the tasks of all timers be executed inline, see
+// Inline for the details.
+func WithInlineTasks() Option {
+	return func(tw *TimingWheel) {
+		tw.inline = true
+	}
+}
+
+// WithInlineGuard sets a handler, which will be called (in the timing wheel's
+// goroutine) with the timer and the elapsed time, whenever an inline task
+// blocks the timing wheel for longer than threshold.
+func WithInlineGuard(threshold time.Duration, handler func(t *Timer, elapsed time.Duration)) Option {
+	return func(tw *TimingWheel) {
+		tw.slowInlineThreshold = threshold
+		tw.slowInline = handler
+	}
+}
+
+// Panic describes a panic raised by a timer's task.
+type Panic struct {
+	Timer *Timer      // The timer whose task panicked.
+	Value interface{} // The value recovered from the panic.
+	Stack []byte      // The stack trace of the goroutine that panicked.
+}
+
+// WithPanicHandler sets a handler, which will be called with the details of
+// the panic, whenever a timer's task panics. Once the handler is set, the
+// panics raised by the tasks will be recovered, instead of crashing the whole
+// program.
+//
+// For a recurring timer, the execution plan continues after its task
+// panics, unless the timer is configured by CancelOnPanic.
+func WithPanicHandler(h func(Panic)) Option {
+	return func(tw *TimingWheel) {
+		tw.panicHandler = h
+	}
+}
 
-	return newTimingWheel(
-		tickMs,
+// TimerOption is a function that configures a Timer.
+type TimerOption func(*Timer)
+
+// Inline makes the timer's task be executed synchronously in the timing
+// wheel's goroutine, instead of in its own goroutine (or by the executor),
+// which saves the cost of starting a goroutine for cheap tasks, such as
+// closing a channel or setting an atomic flag.
+//
+// An inline task must be fast and must never block, since no other timers
+// will fire until it returns. Particularly, it must not wait for other timers
+// to fire. An inline task is free to create, stop or reset timers.
+func Inline() TimerOption {
+	return func(t *Timer) {
+		t.inline = true
+	}
+}
+
+// WithName sets the name of the timer, which helps to tell the timers
+// apart when debugging.
+func WithName(name string) TimerOption {
+	return func(t *Timer) {
+		t.name = name
+	}
+}
+
+// WithPayload attaches an arbitrary value to the timer, which can be
+// retrieved by Timer.Payload, e.g. in the hooks.
+func WithPayload(v interface{}) TimerOption {
+	return func(t *Timer) {
+		t.payload = v
+	}
+}
+
+// CancelOnPanic makes the execution plan of a recurring timer (e.g. the
+// one created by ScheduleFunc) be terminated if its task panics.
+func CancelOnPanic() TimerOption {
+	return func(t *Timer) {
+		t.cancelOnPanic = true
+	}
+}
+
+// NewTimingWheel creates an instance of TimingWheel with the given tick and wheelSize.
+//
+// The tick is the resolution of the timing wheel, which can be as fine as
+// a nanosecond, although a tick of at least tens of microseconds is
+// recommended in practice.
+func NewTimingWheel(tick time.Duration, wheelSize int64, opts ...Option) *TimingWheel {
+	tickNs := int64(tick)
+	if tickNs <= 0 {
+		panic(errors.New("tick must be greater than 0"))
+	}
+
+	tw := newTimingWheel(
+		tickNs,
 		wheelSize,
-		startMs,
+		0,
 		delayqueue.New(int(wheelSize)),
 	)
+	tw.clock = realClock{}
+	tw.executor = goExecutor{}
+	tw.stats = new(wheelStats)
+	for _, opt := range opts {
+		opt(tw)
+	}
+
+	startNs := tw.now()
+	tw.currentTime = truncate(startNs, tickNs)
+
+	return tw
 }
 
 // newTimingWheel is an internal helper function that really creates an instance of TimingWheel.
-func newTimingWheel(tickMs int64, wheelSize int64, startMs int64, queue *delayqueue.DelayQueue) *TimingWheel {
+func newTimingWheel(tickNs int64, wheelSize int64, startNs int64, queue *delayqueue.DelayQueue) *TimingWheel {
+	pending := new(int64)
 	buckets := make([]*bucket, wheelSize)
 	for i := range buckets {
-		buckets[i] = newBucket()
+		buckets[i] = newBucket(pending)
 	}
+
+	interval := tickNs * wheelSize
+	if interval/wheelSize != tickNs {
+		// Overflowed, which may happen to the highest-level overflow wheel.
+		// Such a wheel is able to hold all timers anyway.
+		interval = math.MaxInt64
+	}
+
 	return &TimingWheel{
-		tick:        tickMs,
+		tick:        tickNs,
 		wheelSize:   wheelSize,
-		currentTime: truncate(startMs, tickMs),
-		interval:    tickMs * wheelSize,
+		currentTime: truncate(startNs, tickNs),
+		interval:    interval,
 		buckets:     buckets,
+		pending:     pending,
 		queue:       queue,
 		exitC:       make(chan struct{}),
 	}
 }
 
+// now returns the current time of the clock in nanoseconds.
+func (tw *TimingWheel) now() int64 {
+	return timeToNs(tw.clock.Now().UTC())
+}
+
+// newTimer creates a timer, which fires after delta nanoseconds, for the
+// delay queue to wait for the earliest bucket to expire.
+func (tw *TimingWheel) newTimer(delta int64) delayqueue.Timer {
+	return tw.clock.NewTimer(time.Duration(delta))
+}
+
+// nextTimerID returns the ID of a new timer.
+func (tw *TimingWheel) nextTimerID() uint64 {
+	return atomic.AddUint64(&tw.lastTimerID, 1)
+}
+
 // add inserts the timer t into the current timing wheel.
 func (tw *TimingWheel) add(t *Timer) bool {
 	currentTime := atomic.LoadInt64(&tw.currentTime)
-	if t.expiration < currentTime+tw.tick {
+	expiration := t.getExpiration()
+	// Compare the differences instead of the sums, which may overflow
+	// in the high-level overflow wheels.
+	if expiration-currentTime < tw.tick {
 		// Already expired
 		return false
-	} else if t.expiration < currentTime+tw.interval {
+	} else if expiration-currentTime < tw.interval {
 		// Put it into its own bucket
-		virtualID := t.expiration / tw.tick
+		virtualID := expiration / tw.tick
 		b := tw.buckets[virtualID%tw.wheelSize]
 		b.Add(t)
 
@@ -106,21 +289,236 @@ func (tw *TimingWheel) add(t *Timer) bool {
 	}
 }
 
-// addOrRun inserts the timer t into the current timing wheel, or run the
-// timer's task if it has already expired.
-func (tw *TimingWheel) addOrRun(t *Timer) {
-	if !tw.add(t) {
-		// Already expired
+// submit inserts the new (or reset) timer t into the current timing wheel,
+// or run the timer's task if it has already expired. The timer t will be
+// ignored if the timing wheel has been shut down.
+func (tw *TimingWheel) submit(t *Timer) {
+	var buf [2]timerEvent
+	tw.runEvents(tw.schedule(t, buf[:0]))
+}
 
-		// Like the standard time.AfterFunc (https://golang.org/pkg/time/#AfterFunc),
-		// always execute the timer's task in its own goroutine.
-		go t.task()
+// schedule is like submit, except that instead of being handled, the events
+// of t (including the run of its task if t has already expired) are appended
+// to events, which is returned. It is used when the caller holds t.mu, and
+// thus must handle the events (by runEvents) after releasing t.mu.
+func (tw *TimingWheel) schedule(t *Timer, events []timerEvent) []timerEvent {
+	if atomic.LoadInt32(&tw.closed) == 1 {
+		return events
 	}
+	return tw.addOrCollect(t, false, events)
+}
+
+// reinsert is called by bucket.Flush to insert the timer t into the
+// current timing wheel, or collect t if it has already expired.
+func (tw *TimingWheel) reinsert(t *Timer) {
+	tw.events = tw.addOrCollect(t, true, tw.events)
+}
+
+// addOrCollect inserts the timer t into the current timing wheel, or
+// collects t if it has already expired. The events of t, which happen while
+// a lock is held, are appended to events, which is returned. They must be
+// handled by runEvents once no lock is held.
+//
+// If flushing is true, t is being moved out of a bucket that is being
+// flushed.
+func (tw *TimingWheel) addOrCollect(t *Timer, flushing bool, events []timerEvent) []timerEvent {
+	if tw.add(t) {
+		if flushing {
+			// The timer has been moved down from an overflow wheel.
+			return tw.notify(events, eventCascade, t)
+		}
+		return tw.notify(events, eventAdd, t)
+	}
+	if !flushing {
+		atomic.AddUint64(&tw.stats.expiredOnAdd, 1)
+	}
+
+	// Already expired.
+	//
+	// If flushing, t's bucket is left unchanged until t is added into its new
+	// bucket, or until t is done, so that a concurrent t.Stop or t.Reset will
+	// wait for the flushing (by locking the bucket's mutex) instead of
+	// observing a nil bucket while t is being rescheduled.
+	for {
+		expiration := t.getExpiration()
+		if t.rearm != nil {
+			// The fixed-delay timer will be rearmed after its task returns.
+			atomic.StoreInt32(&t.delayed, 1)
+			t.setBucket(nil)
+			events = tw.notify(events, eventExpire, t)
+			return collect(events, t, expiration, false)
+		}
+		if t.reschedule == nil {
+			t.setBucket(nil)
+			events = tw.notify(events, eventExpire, t)
+			events = collectRelease(events, t)
+			return collect(events, t, expiration, false)
+		}
+
+		next, ok := t.reschedule(expiration)
+		skip := false
+		if ok {
+			next, ok, skip = tw.skipMissed(t, next)
+		}
+		if !skip {
+			events = tw.notify(events, eventExpire, t)
+		}
+		if !ok {
+			// No more executions.
+			t.setBucket(nil)
+			events = collectRelease(events, t)
+			if !skip {
+				events = collect(events, t, expiration, false)
+			}
+			return events
+		}
+
+		// Restart the recurring timer before executing its task, thus the
+		// timer will always stay in a bucket until it's stopped or it ends.
+		t.setExpiration(next)
+		added := tw.add(t)
+		if added {
+			events = tw.notify(events, eventAdd, t)
+		}
+		if !skip {
+			events = collect(events, t, expiration, true)
+		}
+		if added {
+			return events
+		}
+	}
+}
+
+// eventKind is the kind of a timerEvent.
+type eventKind int
+
+const (
+	eventAdd eventKind = iota
+	eventCascade
+	eventExpire
+	eventRelease
+	eventRun
+)
+
+// timerEvent is an event of a timer, which happens while a lock (of the
+// timer or of its bucket) is held, and thus is handled after the lock is
+// released: the observer is notified of the event, or, for eventRelease,
+// the timer is released since it is done, or, for eventRun, the task of
+// the timer is run.
+type timerEvent struct {
+	kind eventKind
+	t    *Timer
+	now  time.Time
+
+	// For eventRun, the execution of the task of t.
+	exec Execution
+}
+
+// collect appends the run of the timer t, which expired at expiration, to
+// events. The expiration may differ from the timer's current expiration,
+// if the timer has been rescheduled.
+func collect(events []timerEvent, t *Timer, expiration int64, rescheduled bool) []timerEvent {
+	return append(events, timerEvent{kind: eventRun, t: t, exec: Execution{
+		Scheduled:   nsToTime(expiration),
+		Missed:      t.takeMissed(),
+		Rescheduled: rescheduled,
+	}})
+}
+
+// collectRelease appends the release of the timer t, which is done (i.e.
+// it has expired for the last time), to events. Thus t is released no matter
+// whether its task will be run or not (e.g. rejected by the executor).
+func collectRelease(events []timerEvent, t *Timer) []timerEvent {
+	if t.release == nil {
+		return events
+	}
+	return append(events, timerEvent{kind: eventRelease, t: t})
+}
+
+// runEvents handles the events in order.
+func (tw *TimingWheel) runEvents(events []timerEvent) {
+	for _, e := range events {
+		switch e.kind {
+		case eventAdd:
+			tw.observer.OnAdd(e.t, e.now)
+		case eventCascade:
+			tw.observer.OnCascade(e.t, e.now)
+		case eventExpire:
+			tw.observer.OnExpire(e.t, e.now)
+		case eventRelease:
+			e.t.release()
+		case eventRun:
+			tw.run(e.t, e.exec)
+		}
+	}
+}
+
+// runCollected handles the events collected during flushing.
+func (tw *TimingWheel) runCollected() {
+	tw.runEvents(tw.events)
+	for i := range tw.events {
+		tw.events[i] = timerEvent{}
+	}
+	tw.events = tw.events[:0]
+}
+
+// run executes the task of the timer t for the execution e.
+func (tw *TimingWheel) run(t *Timer, e Execution) {
+	if t.inline || tw.inline {
+		tw.runInline(t, e)
+		return
+	}
+
+	// By default, like the standard time.AfterFunc (https://golang.org/pkg/time/#AfterFunc),
+	// always execute the timer's task in its own goroutine.
+	tw.tasks.Add()
+	if !tw.executor.Execute(func() {
+		defer tw.tasks.Done()
+		tw.execute(t, e)
+	}) {
+		// The task has been rejected.
+		tw.tasks.Done()
+	}
+}
+
+// execute calls the task of the timer t for the execution e, and recovers
+// the panic raised by the task, if any, when required.
+func (tw *TimingWheel) execute(t *Timer, e Execution) {
+	e.Started = tw.clock.Now()
+	tw.stats.observeLateness(timeToNs(e.Started) - timeToNs(e.Scheduled))
+	if tw.observer != nil {
+		defer func() {
+			tw.observer.OnTaskDone(t, e.Started, tw.clock.Now())
+		}()
+	}
+
+	if tw.panicHandler == nil && !t.cancelOnPanic {
+		t.call(e)
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if t.cancelOnPanic {
+				// Terminate the execution plan.
+				t.Stop()
+			}
+			if tw.panicHandler == nil {
+				panic(r)
+			}
+			tw.panicHandler(Panic{
+				Timer: t,
+				Value: r,
+				Stack: debug.Stack(),
+			})
+		}
+	}()
+	t.call(e)
 }
 
 func (tw *TimingWheel) advanceClock(expiration int64) {
 	currentTime := atomic.LoadInt64(&tw.currentTime)
-	if expiration >= currentTime+tw.tick {
+	if expiration-currentTime >= tw.tick {
 		currentTime = truncate(expiration, tw.tick)
 		atomic.StoreInt64(&tw.currentTime, currentTime)
 
@@ -132,25 +530,56 @@ func (tw *TimingWheel) advanceClock(expiration int64) {
 	}
 }
 
+// runInline executes the task of the expired timer t in the current
+// goroutine, and reports the task if it blocks for too long.
+func (tw *TimingWheel) runInline(t *Timer, e Execution) {
+	if tw.slowInline == nil {
+		tw.execute(t, e)
+		return
+	}
+
+	start := time.Now()
+	tw.execute(t, e)
+	if elapsed := time.Since(start); elapsed > tw.slowInlineThreshold {
+		tw.slowInline(t, elapsed)
+	}
+}
+
+// flush advances the clock to the expiration of the bucket b, and then
+// flushes all the timers of b.
+func (tw *TimingWheel) flush(b *bucket) {
+	tw.advanceClock(b.Expiration())
+	b.Flush(tw.reinsert)
+
+	// Handle the events after b is unlocked, thus the observer and the
+	// inline tasks are free to stop or reset any timers, including the ones
+	// in b.
+	tw.runCollected()
+}
+
+// flushExpired flushes all the buckets that have expired by now. It is
+// called by the clocks that drive the timing wheel by themselves.
+func (tw *TimingWheel) flushExpired() {
+	for {
+		elem, ok := tw.queue.Shift(tw.now())
+		if !ok {
+			return
+		}
+		tw.flush(elem.(*bucket))
+	}
+}
+
 // Start starts the current timing wheel.
 func (tw *TimingWheel) Start() {
-	tw.waitGroup.Wrap(func() {
-		tw.queue.Poll(tw.exitC, func() int64 {
-			return timeToMs(time.Now().UTC())
-		})
-	})
+	if d, ok := tw.clock.(wheelDriver); ok {
+		d.addWheel(tw)
+		return
+	}
 
 	tw.waitGroup.Wrap(func() {
-		for {
-			select {
-			case elem := <-tw.queue.C:
-				b := elem.(*bucket)
-				tw.advanceClock(b.Expiration())
-				b.Flush(tw.addOrRun)
-			case <-tw.exitC:
-				return
-			}
-		}
+		tw.queue.PollFunc(tw.exitC, tw.now, tw.newTimer, func(elem interface{}) {
+			tw.flush(elem.(*bucket))
+		})
 	})
 }
 
@@ -158,69 +587,248 @@ func (tw *TimingWheel) Start() {
 //
 // If there is any timer's task being running in its own goroutine, Stop does
 // not wait for the task to complete before returning. If the caller needs to
-// know whether the task is completed, it must coordinate with the task explicitly.
+// know whether the task is completed, it must coordinate with the task explicitly,
+// or use Shutdown instead.
 func (tw *TimingWheel) Stop() {
-	close(tw.exitC)
-	tw.waitGroup.Wait()
+	tw.stopOnce.Do(func() {
+		if d, ok := tw.clock.(wheelDriver); ok {
+			d.removeWheel(tw)
+		}
+		close(tw.exitC)
+		tw.waitGroup.Wait()
+	})
+}
+
+// ShutdownMode determines what Shutdown does with the pending timers.
+type ShutdownMode int
+
+const (
+	// DiscardPending removes the pending timers from the timing wheel
+	// without firing them, and Shutdown returns them to the caller.
+	DiscardPending ShutdownMode = iota
+
+	// FirePending fires all the pending timers immediately, regardless
+	// of their expiration times. A recurring timer is fired only once.
+	FirePending
+)
+
+// Shutdown gracefully stops the current timing wheel. Unlike Stop, Shutdown:
+//
+//   - stops accepting new timers, any timer created (or reset) afterwards will never fire;
+//   - handles the timers that are still pending according to mode;
+//   - waits for all the timers' tasks, which are running in their own goroutines, to complete.
+//
+// For DiscardPending, the discarded timers are returned, ordered by their
+// expiration times. If ctx expires before
+// all the tasks complete, Shutdown returns the context's error.
+func (tw *TimingWheel) Shutdown(ctx context.Context, mode ShutdownMode) ([]*Timer, error) {
+	atomic.StoreInt32(&tw.closed, 1)
+	tw.Stop()
+
+	// Since the timing wheel has been stopped, no timers will be moved
+	// concurrently while draining the buckets.
+	timers := tw.drain()
+	if mode == FirePending {
+		for _, t := range timers {
+			tw.onExpire(t)
+			tw.run(t, Execution{Scheduled: t.Expiration(), Missed: t.takeMissed()})
+		}
+		timers = nil
+	}
+
+	select {
+	case <-tw.tasks.Idle():
+		return timers, nil
+	case <-ctx.Done():
+		return timers, ctx.Err()
+	}
+}
+
+// StopAndDrain stops the current timing wheel, and returns all the timers
+// that are still pending (ordered by their expiration times), so that the
+// caller can persist them or hand them over to another timing wheel.
+//
+// Like Shutdown, StopAndDrain stops accepting new timers. Unlike Shutdown,
+// StopAndDrain does not wait for the running tasks to complete.
+func (tw *TimingWheel) StopAndDrain() []*Timer {
+	atomic.StoreInt32(&tw.closed, 1)
+	tw.Stop()
+	return tw.drain()
+}
+
+// drain removes all the pending timers from the current timing wheel
+// and all of its overflow wheels, and returns them ordered by their
+// expiration times.
+func (tw *TimingWheel) drain() (timers []*Timer) {
+	for w := tw; w != nil; w = (*TimingWheel)(atomic.LoadPointer(&w.overflowWheel)) {
+		for _, b := range w.buckets {
+			timers = b.Drain(timers)
+		}
+	}
+	for _, t := range timers {
+		if t.release != nil {
+			t.release()
+		}
+	}
+	tw.keys.clear()
+
+	sort.SliceStable(timers, func(i, j int) bool {
+		return timers[i].getExpiration() < timers[j].getExpiration()
+	})
+	return
 }
 
 // AfterFunc waits for the duration to elapse and then calls f in its own goroutine.
 // It returns a Timer that can be used to cancel the call using its Stop method.
-func (tw *TimingWheel) AfterFunc(d time.Duration, f func()) *Timer {
+//
+// The timer can be configured by opts, e.g. Inline.
+func (tw *TimingWheel) AfterFunc(d time.Duration, f func(), opts ...TimerOption) *Timer {
 	t := &Timer{
-		expiration: timeToMs(time.Now().UTC().Add(d)),
+		id:         tw.nextTimerID(),
+		expiration: timeToNs(tw.clock.Now().UTC().Add(d)),
 		task:       f,
+		tw:         tw,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	tw.submit(t)
+	return t
+}
+
+// NewTimer creates a new Timer that will send the current time on its
+// channel C after at least duration d.
+//
+// Like the standard time.NewTimer (https://golang.org/pkg/time/#NewTimer),
+// the channel C has a buffer of one element, and the timer will never block
+// on sending to C. Since no goroutine is started for the sending, NewTimer
+// is cheaper than AfterFunc in terms of firing.
+func (tw *TimingWheel) NewTimer(d time.Duration) *Timer {
+	c := make(chan time.Time, 1)
+	t := &Timer{
+		id:         tw.nextTimerID(),
+		expiration: timeToNs(tw.clock.Now().UTC().Add(d)),
+		task: func() {
+			select {
+			case c <- tw.clock.Now():
+			default:
+			}
+		},
+		C:      c,
+		inline: true,
+		tw:     tw,
 	}
-	tw.addOrRun(t)
+	tw.submit(t)
 	return t
 }
 
+// After waits for the duration to elapse and then sends the current time
+// on the returned channel. It is equivalent to NewTimer(d).C.
+//
+// The underlying Timer is not recovered by the garbage collector until the
+// timer fires. If efficiency is a concern, use NewTimer instead and call
+// Timer.Stop if the timer is no longer needed.
+func (tw *TimingWheel) After(d time.Duration) <-chan time.Time {
+	return tw.NewTimer(d).C
+}
+
 // Scheduler determines the execution plan of a task.
 type Scheduler interface {
 	// Next returns the next execution time after the given (previous) time.
 	// It will return a zero time if no next time is scheduled.
 	//
-	// All times must be UTC.
+	// The given time is in UTC, unless the scheduler is a ZonedScheduler.
+	// The returned time can be in any location, since only the instant it
+	// represents matters.
 	Next(time.Time) time.Time
 }
 
+// ZonedScheduler is a Scheduler whose execution plan is defined in terms
+// of the wall clock of a time zone, e.g. "every day at 09:00 Europe/Berlin".
+//
+// The times passed to Next are in the location returned by Location, thus
+// Next can do the calendar arithmetic (e.g. time.Time.AddDate) directly in
+// the wall clock of the location.
+//
+// When the wall clock jumps due to daylight saving time (DST), a zoned
+// scheduler should make sure that:
+//
+//   - An execution time that is skipped by the wall clock (when the clock
+//     springs forward) is still executed, once, at the moment of the
+//     transition.
+//   - An execution time that is repeated by the wall clock (when the clock
+//     falls back) is executed only once, at its first occurrence.
+//
+// The schedulers parsed by the cron subpackage follow these rules.
+type ZonedScheduler interface {
+	Scheduler
+
+	// Location returns the time zone in which the execution plan is defined.
+	Location() *time.Location
+}
+
+// schedulerTime returns t in the location in which s expects its times.
+func schedulerTime(s Scheduler, t time.Time) time.Time {
+	if zs, ok := s.(ZonedScheduler); ok {
+		return t.In(zs.Location())
+	}
+	return t.UTC()
+}
+
 // ScheduleFunc calls f (in its own goroutine) according to the execution
 // plan scheduled by s. It returns a Timer that can be used to cancel the
 // call using its Stop method.
 //
-// If the caller want to terminate the execution plan halfway, it must
-// stop the timer and ensure that the timer is stopped actually, since in
-// the current implementation, there is a gap between the expiring and the
-// restarting of the timer. The wait time for ensuring is short since the
-// gap is very small.
+// The timer is restarted before f is called each time, thus it stays in
+// the timing wheel until the execution plan ends. Stop, which may be called
+// at any time, including from within f, terminates the whole execution plan
+// deterministically, and it returns true if any future execution of f is
+// cancelled.
 //
 // Internally, ScheduleFunc will ask the first execution time (by calling
 // s.Next()) initially, and create a timer if the execution time is non-zero.
 // Afterwards, it will ask the next execution time each time f is about to
 // be executed, and f will be called at the next execution time if the time
-// is non-zero.
-func (tw *TimingWheel) ScheduleFunc(s Scheduler, f func()) (t *Timer) {
-	expiration := s.Next(time.Now().UTC())
+// is non-zero. Since the latter asking happens in the timing wheel's own
+// goroutine, s.Next() should return quickly without blocking.
+//
+// If the next execution time has already passed when it's asked (e.g. the
+// timing wheel falls behind), f is called back to back to catch up, unless
+// another misfire policy is set by WithMisfirePolicy.
+//
+// To compute the next execution time only after f returns, thus never calling
+// f concurrently with itself, use FixedDelay. To skip an execution if the
+// previous one is still running, use NoOverlap.
+//
+// The timer can be configured by opts, e.g. Inline.
+func (tw *TimingWheel) ScheduleFunc(s Scheduler, f func(), opts ...TimerOption) (t *Timer) {
+	expiration := s.Next(schedulerTime(s, tw.clock.Now()))
 	if expiration.IsZero() {
 		// No time is scheduled, return nil.
 		return
 	}
 
 	t = &Timer{
-		expiration: timeToMs(expiration),
-		task: func() {
+		id:         tw.nextTimerID(),
+		expiration: timeToNs(expiration),
+		task:       f,
+		reschedule: func(expiration int64) (int64, bool) {
 			// Schedule the task to execute at the next time if possible.
-			expiration := s.Next(msToTime(t.expiration))
-			if !expiration.IsZero() {
-				t.expiration = timeToMs(expiration)
-				tw.addOrRun(t)
+			next := s.Next(schedulerTime(s, nsToTime(expiration)))
+			if next.IsZero() {
+				return 0, false
 			}
-
-			// Actually execute the task.
-			f()
+			return timeToNs(next), true
 		},
+		tw: tw,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.fixedDelay {
+		tw.delayReschedule(t, s)
 	}
-	tw.addOrRun(t)
+	tw.submit(t)
 
 	return
 }
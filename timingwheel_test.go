@@ -1,12 +1,27 @@
 package timingwheel_test
 
 import (
+	"context"
+	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/RussellLuo/timingwheel"
 )
 
+// newFakeWheel creates and starts a timing wheel, with a tick of 1ms and a
+// size of 20, driven by a FakeClock set to start. The timing wheel is stopped
+// when the test finishes.
+func newFakeWheel(t testing.TB, start time.Time, opts ...timingwheel.Option) (*timingwheel.FakeClock, *timingwheel.TimingWheel) {
+	clock := timingwheel.NewFakeClock(start)
+	opts = append([]timingwheel.Option{timingwheel.WithClock(clock)}, opts...)
+	tw := timingwheel.NewTimingWheel(time.Millisecond, 20, opts...)
+	tw.Start()
+	t.Cleanup(tw.Stop)
+	return clock, tw
+}
+
 func TestTimingWheel_AfterFunc(t *testing.T) {
 	tw := timingwheel.NewTimingWheel(time.Millisecond, 20)
 	tw.Start()
@@ -89,3 +104,547 @@ func TestTimingWheel_ScheduleFunc(t *testing.T) {
 		}
 	}
 }
+
+func TestTimingWheel_AfterFunc_FakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock, tw := newFakeWheel(t, start)
+
+	durations := []time.Duration{
+		1 * time.Millisecond,
+		5 * time.Millisecond,
+		10 * time.Millisecond,
+		50 * time.Millisecond,
+		100 * time.Millisecond,
+		500 * time.Millisecond,
+		1 * time.Second,
+	}
+	for _, d := range durations {
+		t.Run(d.String(), func(t *testing.T) {
+			exitC := make(chan time.Time, 1)
+
+			begin := clock.Now()
+			tw.AfterFunc(d, func() {
+				exitC <- clock.Now()
+			})
+
+			clock.Advance(d)
+
+			want := begin.Add(d)
+			if got := <-exitC; !got.Equal(want) {
+				t.Errorf("Timer(%s) expiration: want %s, got %s", d, want, got)
+			}
+		})
+	}
+}
+
+func TestTimingWheel_StopBeforeExpiration_FakeClock(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	timer := tw.AfterFunc(time.Second, func() {})
+
+	clock.Advance(999 * time.Millisecond)
+	if !timer.Stop() {
+		t.Fatal("Timer fired before its expiration")
+	}
+
+	timer = tw.AfterFunc(time.Second, func() {})
+
+	clock.Advance(time.Second)
+	if timer.Stop() {
+		t.Fatal("Timer did not fire at its expiration")
+	}
+}
+
+func TestTimingWheel_AfterFunc_SubMillisecondTick(t *testing.T) {
+	clock := timingwheel.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tw := timingwheel.NewTimingWheel(10*time.Microsecond, 20, timingwheel.WithClock(clock))
+	tw.Start()
+	defer tw.Stop()
+
+	durations := []time.Duration{
+		10 * time.Microsecond,
+		100 * time.Microsecond,
+		250 * time.Microsecond,
+		500 * time.Microsecond,
+		1500 * time.Microsecond,
+	}
+	for _, d := range durations {
+		t.Run(d.String(), func(t *testing.T) {
+			exitC := make(chan time.Time, 1)
+
+			begin := clock.Now()
+			timer := tw.AfterFunc(d, func() {
+				exitC <- clock.Now()
+			})
+
+			clock.Advance(d - 10*time.Microsecond)
+			if d > 10*time.Microsecond && !timer.Stop() {
+				t.Fatalf("Timer(%s) fired before its expiration", d)
+			}
+			timer = tw.AfterFunc(d, func() {
+				exitC <- clock.Now()
+			})
+			begin = clock.Now()
+
+			clock.Advance(d)
+
+			want := begin.Add(d)
+			if got := <-exitC; !got.Equal(want) {
+				t.Errorf("Timer(%s) expiration: want %s, got %s", d, want, got)
+			}
+		})
+	}
+}
+
+func TestTimingWheel_AfterFunc_LongDuration(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	// A duration long enough to cause the interval of the highest-level
+	// overflow wheel to overflow.
+	d := 100 * 365 * 24 * time.Hour
+	timer := tw.AfterFunc(d, func() {})
+
+	clock.Advance(d - time.Millisecond)
+	if !timer.Stop() {
+		t.Fatalf("Timer(%s) fired before its expiration", d)
+	}
+
+	timer = tw.AfterFunc(d, func() {})
+
+	clock.Advance(d)
+	if timer.Stop() {
+		t.Fatalf("Timer(%s) did not fire at its expiration", d)
+	}
+}
+
+func TestTimer_Reset(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	exitC := make(chan time.Time, 1)
+	timer := tw.AfterFunc(100*time.Millisecond, func() {
+		exitC <- clock.Now()
+	})
+
+	// Push the deadline back before the timer fires.
+	clock.Advance(50 * time.Millisecond)
+	if !timer.Reset(100 * time.Millisecond) {
+		t.Fatal("Reset of an active timer returned false")
+	}
+
+	clock.Advance(99 * time.Millisecond)
+	select {
+	case got := <-exitC:
+		t.Fatalf("Timer fired at %s before its new expiration", got)
+	default:
+	}
+
+	want := clock.Now().Add(time.Millisecond)
+	clock.Advance(time.Millisecond)
+	if got := <-exitC; !got.Equal(want) {
+		t.Fatalf("Timer expiration: want %s, got %s", want, got)
+	}
+
+	// Reset an expired timer.
+	if timer.Reset(10 * time.Millisecond) {
+		t.Fatal("Reset of an expired timer returned true")
+	}
+	want = clock.Now().Add(10 * time.Millisecond)
+	clock.Advance(10 * time.Millisecond)
+	if got := <-exitC; !got.Equal(want) {
+		t.Fatalf("Timer expiration: want %s, got %s", want, got)
+	}
+
+	// Reset a stopped timer.
+	timer.Reset(time.Second)
+	timer.Stop()
+	if timer.Reset(time.Second) {
+		t.Fatal("Reset of a stopped timer returned true")
+	}
+	if !timer.Stop() {
+		t.Fatal("Stop of a reset timer returned false")
+	}
+}
+
+func TestTimingWheel_NewTimer(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	timer := tw.NewTimer(100 * time.Millisecond)
+
+	clock.Advance(99 * time.Millisecond)
+	select {
+	case got := <-timer.C:
+		t.Fatalf("Timer fired at %s before its expiration", got)
+	default:
+	}
+
+	want := clock.Now().Add(time.Millisecond)
+	clock.Advance(time.Millisecond)
+	select {
+	case got := <-timer.C:
+		if !got.Equal(want) {
+			t.Fatalf("Timer expiration: want %s, got %s", want, got)
+		}
+	default:
+		t.Fatal("Timer did not fire at its expiration")
+	}
+
+	if timer.Stop() {
+		t.Fatal("Stop of an expired timer returned true")
+	}
+}
+
+func TestTimingWheel_After(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	c := tw.After(time.Second)
+	want := clock.Now().Add(time.Second)
+
+	clock.Advance(time.Second)
+	select {
+	case got := <-c:
+		if !got.Equal(want) {
+			t.Fatalf("After expiration: want %s, got %s", want, got)
+		}
+	default:
+		t.Fatal("After did not fire at its expiration")
+	}
+}
+
+func TestTimingWheel_ScheduleFunc_Stop(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	s := &scheduler{intervals: []time.Duration{
+		10 * time.Millisecond,
+		10 * time.Millisecond,
+		10 * time.Millisecond,
+		10 * time.Millisecond,
+	}}
+
+	var timer *timingwheel.Timer
+	stoppedC := make(chan bool, 1)
+	var count int32
+	timer = tw.ScheduleFunc(s, func() {
+		if atomic.AddInt32(&count, 1) == 2 {
+			// Stop the execution plan from within f.
+			stoppedC <- timer.Stop()
+		}
+	})
+
+	clock.Advance(20 * time.Millisecond)
+	if stopped := <-stoppedC; !stopped {
+		t.Fatal("Stop from within f returned false")
+	}
+
+	clock.Advance(time.Second)
+	if timer.Stop() {
+		t.Fatal("Stop of a stopped schedule returned true")
+	}
+	if n := atomic.LoadInt32(&count); n != 2 {
+		t.Fatalf("Got (%+v) executions != Want (%+v)", n, 2)
+	}
+}
+
+func TestTimingWheel_ScheduleFunc_StopAtExpiration(t *testing.T) {
+	clock, tw := newFakeWheel(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	s := &scheduler{intervals: []time.Duration{
+		10 * time.Millisecond,
+		10 * time.Millisecond,
+	}}
+	timer := tw.ScheduleFunc(s, func() {})
+
+	// Right after the first execution, the timer is already restarted.
+	clock.Advance(10 * time.Millisecond)
+	if !timer.Stop() {
+		t.Fatal("Stop right after the first execution returned false")
+	}
+
+	// The execution plan ends after the last execution.
+	timer = tw.ScheduleFunc(&scheduler{intervals: []time.Duration{10 * time.Millisecond}}, func() {})
+	clock.Advance(10 * time.Millisecond)
+	if timer.Stop() {
+		t.Fatal("Stop after the plan ends returned true")
+	}
+}
+
+// slowScheduler is a scheduler, whose Next blocks for a while when it is
+// called for the second time, i.e. when the timer is being rescheduled.
+type slowScheduler struct {
+	d        time.Duration
+	calls    int
+	enteredC chan struct{}
+}
+
+func (s *slowScheduler) Next(prev time.Time) time.Time {
+	s.calls++
+	if s.calls == 2 {
+		s.enteredC <- struct{}{}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return prev.Add(s.d)
+}
+
+func TestTimer_StopOrResetWhileRescheduling(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// newTimer creates a recurring timer, and returns it once the timer
+	// is being rescheduled after its first expiration. The returned function
+	// waits for the rescheduling to complete, and then advances the clock
+	// far beyond the following expirations.
+	newTimer := func(t *testing.T) (*timingwheel.TimingWheel, *timingwheel.Timer, *int32, func()) {
+		clock, tw := newFakeWheel(t, start, timingwheel.WithInlineTasks())
+		fired := new(int32)
+		s := &slowScheduler{d: 10 * time.Millisecond, enteredC: make(chan struct{}, 1)}
+		timer := tw.ScheduleFunc(s, func() { atomic.AddInt32(fired, 1) })
+
+		doneC := make(chan struct{})
+		go func() {
+			clock.Advance(10 * time.Millisecond)
+			close(doneC)
+		}()
+		<-s.enteredC
+		return tw, timer, fired, func() {
+			<-doneC
+			clock.Advance(50 * time.Millisecond)
+		}
+	}
+
+	t.Run("stop", func(t *testing.T) {
+		tw, timer, fired, wait := newTimer(t)
+
+		if !timer.Stop() {
+			t.Fatalf("Stop: Got (%+v) != Want (%+v)", false, true)
+		}
+		wait()
+
+		if n := atomic.LoadInt32(fired); n != 1 {
+			t.Fatalf("Got (%+v) fired timers != Want (%+v)", n, 1)
+		}
+		if n := tw.Stats().Pending; n != 0 {
+			t.Fatalf("Pending: Got (%+v) != Want (%+v)", n, 0)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		tw, timer, fired, wait := newTimer(t)
+
+		if !timer.Reset(time.Hour) {
+			t.Fatalf("Reset: Got (%+v) != Want (%+v)", false, true)
+		}
+		wait()
+
+		if n := atomic.LoadInt32(fired); n != 1 {
+			t.Fatalf("Got (%+v) fired timers != Want (%+v)", n, 1)
+		}
+		if n := tw.Stats().Pending; n != 1 {
+			t.Fatalf("Pending: Got (%+v) != Want (%+v)", n, 1)
+		}
+		if !timer.Stop() {
+			t.Fatalf("Stop: Got (%+v) != Want (%+v)", false, true)
+		}
+		if n := tw.Stats().Pending; n != 0 {
+			t.Fatalf("Pending: Got (%+v) != Want (%+v)", n, 0)
+		}
+	})
+}
+
+func TestTimingWheel_Shutdown(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("discard pending", func(t *testing.T) {
+		_, tw := newFakeWheel(t, start)
+
+		tw.AfterFunc(10*time.Millisecond, func() { t.Error("Discarded timer fired") })
+		tw.AfterFunc(time.Hour, func() { t.Error("Discarded timer fired") })
+
+		timers, err := tw.Shutdown(context.Background(), timingwheel.DiscardPending)
+		if err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+		if len(timers) != 2 {
+			t.Fatalf("Got (%+v) discarded timers != Want (%+v)", len(timers), 2)
+		}
+		for _, timer := range timers {
+			if timer.Stop() {
+				t.Fatal("Discarded timer was still active")
+			}
+		}
+
+		// New timers are no longer accepted.
+		timer := tw.AfterFunc(0, func() { t.Error("Timer fired after Shutdown") })
+		if timer.Stop() {
+			t.Fatal("Timer was active after Shutdown")
+		}
+	})
+
+	t.Run("fire pending", func(t *testing.T) {
+		_, tw := newFakeWheel(t, start)
+
+		var count int32
+		tw.AfterFunc(10*time.Millisecond, func() { atomic.AddInt32(&count, 1) })
+		tw.AfterFunc(time.Hour, func() { atomic.AddInt32(&count, 1) })
+
+		timers, err := tw.Shutdown(context.Background(), timingwheel.FirePending)
+		if err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+		if len(timers) != 0 {
+			t.Fatalf("Got (%+v) discarded timers != Want (%+v)", len(timers), 0)
+		}
+		// Shutdown waits for the fired tasks to complete.
+		if n := atomic.LoadInt32(&count); n != 2 {
+			t.Fatalf("Got (%+v) fired timers != Want (%+v)", n, 2)
+		}
+	})
+
+	t.Run("wait for running tasks", func(t *testing.T) {
+		clock, tw := newFakeWheel(t, start)
+
+		startedC := make(chan struct{})
+		releaseC := make(chan struct{})
+		tw.AfterFunc(10*time.Millisecond, func() {
+			close(startedC)
+			<-releaseC
+		})
+		clock.Advance(10 * time.Millisecond)
+		<-startedC
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := tw.Shutdown(ctx, timingwheel.DiscardPending); err != context.DeadlineExceeded {
+			t.Fatalf("Shutdown: got error %v, want %v", err, context.DeadlineExceeded)
+		}
+
+		close(releaseC)
+		if _, err := tw.Shutdown(context.Background(), timingwheel.DiscardPending); err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+	})
+}
+
+func TestTimingWheel_StopAndDrain(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock, tw := newFakeWheel(t, start)
+
+	durations := []time.Duration{
+		time.Hour,
+		5 * time.Millisecond,
+		time.Second,
+		50 * time.Millisecond,
+		10 * time.Millisecond, // expired before draining
+	}
+	for _, d := range durations {
+		tw.AfterFunc(d, func() {})
+	}
+	clock.Advance(10 * time.Millisecond)
+
+	timers := tw.StopAndDrain()
+
+	want := []time.Duration{
+		50 * time.Millisecond,
+		time.Second,
+		time.Hour,
+	}
+	if len(timers) != len(want) {
+		t.Fatalf("Got (%+v) pending timers != Want (%+v)", len(timers), len(want))
+	}
+	for i, d := range want {
+		if got := timers[i].Expiration(); !got.Equal(start.Add(d)) {
+			t.Errorf("Timer %d expiration: want %s, got %s", i, start.Add(d), got)
+		}
+	}
+}
+
+func TestTimer_Metadata(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, tw := newFakeWheel(t, start)
+
+	type session struct{ user string }
+	payload := &session{user: "alice"}
+
+	t1 := tw.AfterFunc(time.Second, func() {},
+		timingwheel.WithName("session-timeout"),
+		timingwheel.WithPayload(payload),
+	)
+	t2 := tw.ScheduleFunc(&scheduler{intervals: []time.Duration{time.Minute}}, func() {})
+	defer t1.Stop()
+	defer t2.Stop()
+
+	if t1.ID() == 0 || t2.ID() == 0 || t1.ID() == t2.ID() {
+		t.Fatalf("IDs: Got (%+v, %+v) != Want unique non-zero IDs", t1.ID(), t2.ID())
+	}
+	if t1.Name() != "session-timeout" || t1.Payload() != payload {
+		t.Fatalf("Got (%+v, %+v) != Want (%+v, %+v)", t1.Name(), t1.Payload(), "session-timeout", payload)
+	}
+	if t2.Name() != "" || t2.Payload() != nil {
+		t.Fatalf("Got (%+v, %+v) != Want (%+v, %+v)", t2.Name(), t2.Payload(), "", nil)
+	}
+	if !t1.Expiration().Equal(start.Add(time.Second)) {
+		t.Fatalf("Got (%+v) != Want (%+v)", t1.Expiration(), start.Add(time.Second))
+	}
+
+	want := fmt.Sprintf("timer#%d(session-timeout) expiring at 2020-01-01T00:00:01Z", t1.ID())
+	if got := t1.String(); got != want {
+		t.Fatalf("Got (%+v) != Want (%+v)", got, want)
+	}
+
+	// The metadata is available to the introspection APIs.
+	timers := tw.StopAndDrain()
+	if len(timers) != 2 || timers[0].Payload() != payload {
+		t.Fatalf("Got (%+v) != Want the timer carrying (%+v) first", timers, payload)
+	}
+}
+
+// dailyScheduler executes at the same wall-clock time every day.
+type dailyScheduler struct {
+	loc  *time.Location
+	prev []time.Time
+}
+
+func (s *dailyScheduler) Next(prev time.Time) time.Time {
+	s.prev = append(s.prev, prev)
+	return prev.AddDate(0, 0, 1)
+}
+
+func (s *dailyScheduler) Location() *time.Location {
+	return s.loc
+}
+
+func TestTimingWheel_ScheduleFunc_ZonedScheduler(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("Failed to load location: %v", err)
+	}
+
+	// 09:00 CET, one day before the clock springs forward.
+	start := time.Date(2021, 3, 27, 8, 0, 0, 0, time.UTC)
+	clock, tw := newFakeWheel(t, start, timingwheel.WithInlineTasks())
+
+	s := &dailyScheduler{loc: berlin}
+	var fired []time.Time
+	tw.ScheduleFunc(s, func() {
+		fired = append(fired, clock.Now())
+	})
+
+	clock.Advance(48 * time.Hour)
+
+	// Still 09:00, but in CEST.
+	want := []time.Time{
+		time.Date(2021, 3, 28, 7, 0, 0, 0, time.UTC),
+		time.Date(2021, 3, 29, 7, 0, 0, 0, time.UTC),
+	}
+	if len(fired) != len(want) {
+		t.Fatalf("Got (%+v) != Want (%+v)", fired, want)
+	}
+	for i := range want {
+		if !fired[i].Equal(want[i]) {
+			t.Fatalf("Got (%+v) != Want (%+v)", fired, want)
+		}
+	}
+
+	for _, prev := range s.prev {
+		if prev.Location() != berlin {
+			t.Fatalf("Location: Got (%+v) != Want (%+v)", prev.Location(), berlin)
+		}
+	}
+}
@@ -14,15 +14,15 @@ func truncate(x, m int64) int64 {
 	return x - x%m
 }
 
-// timeToMs returns an integer number, which represents t in milliseconds.
-func timeToMs(t time.Time) int64 {
-	return t.UnixNano() / int64(time.Millisecond)
+// timeToNs returns an integer number, which represents t in nanoseconds.
+func timeToNs(t time.Time) int64 {
+	return t.UnixNano()
 }
 
-// msToTime returns the UTC time corresponding to the given Unix time,
-// t milliseconds since January 1, 1970 UTC.
-func msToTime(t int64) time.Time {
-	return time.Unix(0, t*int64(time.Millisecond)).UTC()
+// nsToTime returns the UTC time corresponding to the given Unix time,
+// t nanoseconds since January 1, 1970 UTC.
+func nsToTime(t int64) time.Time {
+	return time.Unix(0, t).UTC()
 }
 
 type waitGroupWrapper struct {
@@ -36,3 +36,43 @@ func (w *waitGroupWrapper) Wrap(cb func()) {
 		w.Done()
 	}()
 }
+
+// taskGroup tracks whether there are any tasks still running.
+type taskGroup struct {
+	mu    sync.Mutex
+	n     int
+	idleC chan struct{} // closed once n drops to zero
+}
+
+// Add adds a running task to g.
+func (g *taskGroup) Add() {
+	g.mu.Lock()
+	if g.n == 0 {
+		g.idleC = make(chan struct{})
+	}
+	g.n++
+	g.mu.Unlock()
+}
+
+// Done removes a running task, which has completed, from g.
+func (g *taskGroup) Done() {
+	g.mu.Lock()
+	g.n--
+	if g.n == 0 {
+		close(g.idleC)
+	}
+	g.mu.Unlock()
+}
+
+// Idle returns a channel that is closed once there are no tasks running.
+func (g *taskGroup) Idle() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.n == 0 {
+		c := make(chan struct{})
+		close(c)
+		return c
+	}
+	return g.idleC
+}